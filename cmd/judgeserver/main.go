@@ -7,10 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"runtime"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sshwy/yaoj-core/pkg/private/judger"
+	"github.com/sshwy/yaoj-core/pkg/private/processors"
+	"github.com/sshwy/yaoj-core/pkg/private/run"
 	"github.com/sshwy/yaoj-core/pkg/problem"
 )
 
@@ -36,15 +41,44 @@ func (r *Storage) Get(checksum string) problem.Problem {
 var storage = Storage{Map: sync.Map{}}
 
 var address string
+var sandboxes int
+var backend string
+var langs string
+var cachedir string
+var cachesize int64
+var timeout time.Duration
+var testWorkers int
+var problemdir string
 
 func main() {
 	flag.Parse()
-
-	var cachedir = path.Join(os.TempDir(), "yaoj-judger-server-cache")
-	os.RemoveAll(cachedir)
-	if err := os.MkdirAll(cachedir, os.ModePerm); err != nil {
+	if err := judger.SetBackend(backend); err != nil {
 		log.Fatal(err)
 	}
+	judger.SetPool(judger.NewPool(sandboxes))
+	if langs != "" {
+		table, err := processors.LoadLangTable(langs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		processors.SetLangs(table)
+	}
+
+	if cachedir != "" {
+		cache, err := run.NewDiskCache(cachedir, cachesize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run.SetCache(cache)
+	}
+
+	if problemdir != "" {
+		if err := os.MkdirAll(problemdir, os.ModePerm); err != nil {
+			log.Fatal(err)
+		}
+		// problems are available once prepared
+		go loadProblems(problemdir)
+	}
 
 	r := gin.Default()
 	r.POST("/judge", Judge)
@@ -73,6 +107,14 @@ func main() {
 
 func init() {
 	flag.StringVar(&address, "listen", "localhost:3000", "listening address")
+	flag.IntVar(&sandboxes, "sandboxes", runtime.NumCPU(), "maximum number of sandboxes running at the same time")
+	flag.StringVar(&backend, "backend", "", "judger backend (default yaoj-judger if available)")
+	flag.StringVar(&langs, "langs", "", "language table (JSON) used by compiler:auto")
+	flag.StringVar(&cachedir, "cache", path.Join(os.TempDir(), "yaoj-judger-server-cache"), "directory of persistent workflow cache (empty for in-memory cache)")
+	flag.IntVar(&testWorkers, "testworkers", 1, "maximum number of testcases of a judgement running at the same time")
+	flag.DurationVar(&timeout, "timeout", 0, "time limit of a whole judgement (0 for no limitation)")
+	flag.Int64Var(&cachesize, "cachesize", 1<<30, "maximum size of workflow cache in bytes")
+	flag.StringVar(&problemdir, "problems", "", "directory where synced problems are saved and loaded at startup (empty for none)")
 }
 
 var logger = log.New(os.Stderr, "[judgeserver] ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix)
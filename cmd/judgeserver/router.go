@@ -2,9 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sshwy/yaoj-core/pkg/private/run"
@@ -16,6 +24,8 @@ func Judge(ctx *gin.Context) {
 	type Judge struct {
 		Callback string `form:"cb" binding:"required"`
 		Checksum string `form:"sum" binding:"required"`
+		// optional url receiving progress events
+		Progress string `form:"progress"`
 	}
 	var qry Judge
 	err := ctx.BindQuery(&qry)
@@ -48,6 +58,13 @@ func Judge(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// removed here unless the judgement, which owns it then, is started
+	judging := false
+	defer func() {
+		if !judging {
+			os.RemoveAll(tmpdir)
+		}
+	}()
 
 	submission, err := problem.LoadSubm(file.Name(), tmpdir)
 	if err != nil {
@@ -58,11 +75,32 @@ func Judge(ctx *gin.Context) {
 	// ready to judge
 	ctx.JSON(http.StatusOK, gin.H{"message": "ok"})
 
+	judging = true
 	go func() {
-		result, err := run.RunProblem(prob.Data(), tmpdir, submission)
+		defer os.RemoveAll(tmpdir)
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		options := []run.OptionProvider{run.WithTestWorkers(testWorkers)}
+		var reporter *progressReporter
+		if qry.Progress != "" {
+			reporter = newProgressReporter(qry.Progress)
+			options = append(options, run.WithObserver(reporter.observe))
+		}
+		result, err := run.RunProblemContext(ctx, prob.Data(), tmpdir, submission, options...)
+		// progress never arrives after the result
+		if reporter != nil {
+			reporter.close()
+		}
 		if err != nil {
 			logger.Printf("run problem: %v", err)
-			return
+			// partial result of a cancelled judgement is still reported
+			if result == nil {
+				return
+			}
 		}
 		logger.Print(result.Brief())
 
@@ -70,10 +108,62 @@ func Judge(ctx *gin.Context) {
 		if err != nil {
 			logger.Printf("callback request error: %v", err)
 		}
-		os.RemoveAll(tmpdir)
 	}()
 }
 
+// Post progress events to url in order in background. Events are dropped
+// when posting falls behind, so that judging never waits for it.
+type progressReporter struct {
+	url     string
+	events  chan run.Event
+	dropped int64
+	done    chan struct{}
+}
+
+var progressClient = &http.Client{Timeout: 10 * time.Second}
+
+func newProgressReporter(url string) *progressReporter {
+	r := &progressReporter{url: url, events: make(chan run.Event, 64), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		for event := range r.events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Printf("marshal event: %v", err)
+				continue
+			}
+			resp, err := progressClient.Post(r.url, "text/json; charset=utf-8", bytes.NewReader(data))
+			if err != nil {
+				logger.Printf("progress request error: %v", err)
+				continue
+			}
+			resp.Body.Close()
+		}
+	}()
+	return r
+}
+
+// Observer of the judgement, where events of nodes are ignored.
+func (r *progressReporter) observe(event run.Event) {
+	if event.Type == run.EventNodeStarted || event.Type == run.EventNodeFinished {
+		return
+	}
+	select {
+	case r.events <- event:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Wait for events to be posted. observe must not be called afterwards.
+func (r *progressReporter) close() {
+	close(r.events)
+	<-r.done
+	if dropped := atomic.LoadInt64(&r.dropped); dropped > 0 {
+		logger.Printf("%d progress events dropped", dropped)
+	}
+}
+
 func Sync(ctx *gin.Context) {
 	type Sync struct {
 		Checksum string `form:"sum" binding:"required"`
@@ -85,13 +175,14 @@ func Sync(ctx *gin.Context) {
 		return
 	}
 	// store problem
-	file, _ := os.CreateTemp(os.TempDir(), "prob-*.zip")
-	io.Copy(file, ctx.Request.Body)
+	file, err := os.CreateTemp(os.TempDir(), "prob-*.zip")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	_, err = io.Copy(file, ctx.Request.Body)
 	file.Close()
 	defer os.Remove(file.Name())
-
-	probdir, _ := os.MkdirTemp(os.TempDir(), "prob-*")
-	prob, err := problem.LoadDump(file.Name(), probdir)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -105,8 +196,122 @@ func Sync(ctx *gin.Context) {
 		})
 		return
 	}
+
+	prob, warnings, err := loadProblem(ctx.Request.Context(), file.Name(), qry.Checksum)
+	if err != nil {
+		var lerr *loadError
+		if errors.As(err, &lerr) {
+			res := gin.H{
+				"error":      lerr.Msg,
+				"error_code": lerr.Code,
+				"warnings":   warnings,
+			}
+			if lerr.Diagnostics != nil {
+				res["diagnostics"] = lerr.Diagnostics
+			}
+			ctx.JSON(http.StatusBadRequest, res)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if problemdir != "" {
+		if _, err := utils.CopyFile(file.Name(), path.Join(problemdir, qry.Checksum+".zip")); err != nil {
+			logger.Printf("save problem %s: %v", qry.Checksum, err)
+		}
+	}
 	storage.Set(qry.Checksum, prob)
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "ok",
+		"message":  "ok",
+		"warnings": warnings,
 	})
 }
+
+// Error of problem data, reported to the problem setter.
+type loadError struct {
+	// error_code of the response
+	Code int
+	Msg  string
+	// errors found by validation
+	Diagnostics []string
+}
+
+func (r *loadError) Error() string {
+	return r.Msg
+}
+
+// Load a problem dump, then validate it and run its nodes independent of
+// submissions, so that data errors are found (as *loadError) before any
+// submission. Warnings of validation are returned in either case.
+func loadProblem(ctx context.Context, name string, checksum string) (problem.Problem, []string, error) {
+	probdir, err := os.MkdirTemp(os.TempDir(), "prob-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	prob, err := problem.LoadDump(name, probdir)
+	if err != nil {
+		os.RemoveAll(probdir)
+		return nil, nil, err
+	}
+
+	diags := problem.Validate(prob.Data())
+	warnings, errs := []string{}, []string{}
+	for _, diag := range diags.Filter(problem.Warning) {
+		logger.Printf("validate %s: %s", checksum, diag)
+		if diag.Level == problem.Warning {
+			warnings = append(warnings, diag.String())
+		}
+	}
+	for _, diag := range diags.Filter(problem.Error) {
+		errs = append(errs, diag.String())
+	}
+	if diags.HasError() {
+		os.RemoveAll(probdir)
+		return nil, warnings, &loadError{Code: 3, Msg: "invalid problem data", Diagnostics: errs}
+	}
+
+	// run nodes independent of submissions, reporting data errors
+	prepdir, err := os.MkdirTemp(os.TempDir(), "prob-prepare-*")
+	if err != nil {
+		os.RemoveAll(probdir)
+		return nil, warnings, err
+	}
+	// caches keep their own copies of output files, which outlive prepdir
+	defer os.RemoveAll(prepdir)
+	err = run.PrepareProblem(ctx, prob.Data(), prepdir, run.WithTestWorkers(testWorkers))
+	if err != nil {
+		os.RemoveAll(probdir)
+		var failures run.PrepareErrors
+		if errors.As(err, &failures) {
+			return nil, warnings, &loadError{Code: 2, Msg: "prepare: " + err.Error()}
+		}
+		return nil, warnings, fmt.Errorf("prepare: %w", err)
+	}
+	return prob, warnings, nil
+}
+
+// Load problems saved in dir by Sync, which are named by their checksums.
+func loadProblems(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Printf("load problems: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		checksum := strings.TrimSuffix(entry.Name(), ".zip")
+		if entry.IsDir() || checksum == entry.Name() {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		if chk := utils.FileChecksum(name).String(); chk != checksum {
+			logger.Printf("load problem %s: invalid checksum %s", checksum, chk)
+			continue
+		}
+		prob, _, err := loadProblem(context.Background(), name, checksum)
+		if err != nil {
+			logger.Printf("load problem %s: %v", checksum, err)
+			continue
+		}
+		storage.Set(checksum, prob)
+	}
+}
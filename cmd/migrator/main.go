@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"github.com/sshwy/yaoj-core/pkg/migrator"
+	"github.com/sshwy/yaoj-core/pkg/problem"
 	"github.com/sshwy/yaoj-core/pkg/utils"
 )
 
@@ -30,10 +31,13 @@ func Main() error {
 	}
 
 	if dumpFile == "" {
-		_, err = mig.Migrate(srcDir, destDir)
+		prob, err := mig.Migrate(srcDir, destDir)
 		if err != nil {
 			return err
 		}
+		if err := validate(prob); err != nil {
+			return err
+		}
 	} else {
 		dir, err := os.MkdirTemp(os.TempDir(), "yaoj-migrator-******")
 		if err != nil {
@@ -43,6 +47,9 @@ func Main() error {
 		if err != nil {
 			return err
 		}
+		if err := validate(prob); err != nil {
+			return err
+		}
 		dest := path.Join(destDir, dumpFile)
 		err = prob.DumpFile(dest)
 		if err != nil {
@@ -59,6 +66,18 @@ func Main() error {
 	return nil
 }
 
+// print diagnostics of the migrated problem
+func validate(prob problem.Problem) error {
+	diags := problem.Validate(prob.Data())
+	for _, diag := range diags {
+		fmt.Printf("[%s]: %s: %s\n", diag.Level, diag.Where, diag.Msg)
+	}
+	if diags.HasError() {
+		return fmt.Errorf("invalid problem data")
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -10,5 +10,7 @@ int main(int argc, char * argv[])
     double ja = ans.readDouble();
     double pa = ouf.readDouble();
 
-    quitp(fabs(ja - pa), "ja=%.4f pa=%.4f", ja, pa);
+    // points are the ratio of score in [0, 1]: full score if exact, none if
+    // the error is at least 1
+    quitp(max(0.0, 1.0 - fabs(ja - pa)), "ja=%.4f pa=%.4f", ja, pa);
 }
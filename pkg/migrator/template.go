@@ -0,0 +1,67 @@
+package migrator
+
+import (
+	"strings"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+// Workflow of traditional problems. Static data: "checker" (testlib checker
+// source, only required when checker is empty). Tests: "input" and "output".
+// Submission: "source". limit is given to runner:stdio as a constant.
+//
+// If checker is not empty, native checker "checker:<checker>" (e.g. wcmp) is
+// used instead of compiling one.
+func TraditionalGraph(checker string, limit string) (*workflow.WorkflowGraph, error) {
+	var builder workflow.Builder
+	builder.SetNode("compile_source", "compiler:auto", false)
+	builder.SetNode("run", "runner:stdio", true)
+	builder.AddConstant(limit, "run", "limit")
+	builder.AddInbound(workflow.Gsubm, "source", "compile_source", "source")
+	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	builder.AddEdge("compile_source", "result", "run", "executable")
+	addChecker(&builder, checker, "run", "stdout")
+	return builder.WorkflowGraph()
+}
+
+// Workflow of interactive problems. Besides those of traditional problems,
+// static data "interactor" (testlib interactor source) is required. Output of
+// the interactor is checked by the checker.
+func InteractiveGraph(checker string, limit string) (*workflow.WorkflowGraph, error) {
+	var builder workflow.Builder
+	builder.SetNode("compile_source", "compiler:auto", false)
+	builder.SetNode("compile_interactor", "compiler:testlib", false)
+	builder.SetNode("run", "runner:interactive", true)
+	builder.AddConstant(limit, "run", "limit")
+	builder.AddInbound(workflow.Gstatic, "interactor", "compile_interactor", "source")
+	builder.AddInbound(workflow.Gsubm, "source", "compile_source", "source")
+	builder.AddInbound(workflow.Gtests, "input", "run", "input")
+	builder.AddEdge("compile_source", "result", "run", "executable")
+	builder.AddEdge("compile_interactor", "result", "run", "interactor")
+	addChecker(&builder, checker, "run", "result")
+	return builder.WorkflowGraph()
+}
+
+// Add node "check" checking output of node "from".
+func addChecker(builder *workflow.Builder, checker string, from, frlabel string) {
+	if checker != "" {
+		builder.SetNode("check", "checker:"+checker, false)
+	} else {
+		builder.SetNode("compile_checker", "compiler:testlib", false)
+		builder.SetNode("check", "checker:testlib", false)
+		builder.AddInbound(workflow.Gstatic, "checker", "compile_checker", "source")
+		builder.AddEdge("compile_checker", "result", "check", "checker")
+	}
+	builder.AddInbound(workflow.Gtests, "input", "check", "input")
+	builder.AddInbound(workflow.Gtests, "output", "check", "answer")
+	builder.AddEdge(from, frlabel, "check", "output")
+}
+
+// Whether "checker:<name>" is a native testlib compatible checker.
+func hasNativeChecker(name string) bool {
+	procName := "checker:" + name
+	input, output := processor.InputLabel(procName), processor.OutputLabel(procName)
+	return strings.Join(input, ",") == "input,output,answer" &&
+		len(output) > 0 && output[0] == "xmlreport"
+}
@@ -1,7 +1,6 @@
 package migrator
 
 import (
-	"bytes"
 	"fmt"
 	"io/fs"
 	"log"
@@ -100,7 +99,11 @@ func (r Uoj) Migrate(src string, dest string) (Problem, error) {
 	}
 
 	// parse checker
-	if _, ok := conf["use_builtin_checker"]; ok {
+	var checker string // native checker
+	if name, ok := conf["use_builtin_checker"]; ok && hasNativeChecker(name) {
+		logger.Printf("use native checker: %q", name)
+		checker = name
+	} else if ok {
 		logger.Printf("use builtin checker: %q", conf["use_builtin_checker"])
 		// copy checker
 		file, err := asserts.Open(path.Join("asserts", "checker", conf["use_builtin_checker"]+".cpp"))
@@ -125,7 +128,7 @@ func (r Uoj) Migrate(src string, dest string) (Problem, error) {
 	tl := parseInt(conf["time_limit"])
 	ml := parseInt(conf["memory_limit"])
 	ol := parseInt(conf["output_limit"])
-	limReader := bytes.NewReader([]byte(fmt.Sprintf(
+	limit := fmt.Sprintf(
 		"%d %d %d %d %d %d %d",
 		1000*60, // 1min
 		1000*tl,
@@ -134,31 +137,23 @@ func (r Uoj) Migrate(src string, dest string) (Problem, error) {
 		1024*1024*ml,
 		1024*1024*ol,
 		50,
-	)))
-	plim, err := prob.AddFileReader("lim.txt", limReader)
-	if err != nil {
-		return nil, err
-	}
-	prob.Static["limitation"] = plim
+	)
 	prob.Statement["_tl"] = fmt.Sprint(tl * 1000)
 	prob.Statement["_ml"] = conf["memory_limit"]
 	prob.Statement["_ol"] = conf["output_limit"]
 
-	var builder workflow.Builder
-	builder.SetNode("compile_source", "compiler:auto", false)
-	builder.SetNode("compile_checker", "compiler:testlib", false)
-	builder.SetNode("check", "checker:testlib", false)
-	builder.SetNode("run", "runner:stdio", true)
-	builder.AddInbound(workflow.Gstatic, "limitation", "run", "limit")
-	builder.AddInbound(workflow.Gstatic, "checker", "compile_checker", "source")
-	builder.AddInbound(workflow.Gsubm, "source", "compile_source", "source")
-	builder.AddInbound(workflow.Gtests, "input", "run", "stdin")
-	builder.AddInbound(workflow.Gtests, "input", "check", "input")
-	builder.AddInbound(workflow.Gtests, "output", "check", "answer")
-	builder.AddEdge("compile_source", "result", "run", "executable")
-	builder.AddEdge("compile_checker", "result", "check", "checker")
-	builder.AddEdge("run", "stdout", "check", "output")
-	graph, err := builder.WorkflowGraph()
+	var graph *workflow.WorkflowGraph
+	if conf["interaction_mode"] == "on" {
+		var pitct string
+		pitct, err = prob.AddFile("interactor.cpp", path.Join(src, "data", "interactor.cpp"))
+		if err != nil {
+			return nil, err
+		}
+		prob.Static["interactor"] = pitct
+		graph, err = InteractiveGraph(checker, limit)
+	} else {
+		graph, err = TraditionalGraph(checker, limit)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,110 @@
+package judger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StatusCode describes final status of a execution. Note that Ok is
+// the only status for success
+type StatusCode int
+
+const (
+	Ok StatusCode = iota
+	RuntimeError
+	MemoryExceed
+	TimeExceed
+	OoutputExceed
+	SystemError
+	DangerousSyscall
+	ExitError
+)
+
+type LimitType int
+
+const (
+	realTime LimitType = iota
+	cpuTime
+	// virtual memory
+	virtMem
+	realMem
+	stackMem
+	// output size
+	outputSize
+	filenoLim
+)
+
+type Runner int
+
+// Runner type
+const (
+	General     Runner = 0
+	Interactive Runner = 1
+)
+
+// short cut for Limitation
+type L map[LimitType]int64
+
+// Backend performs a judgement described by option in a sandbox.
+//
+// Two backends are provided: "yaoj-judger", the cgo binding of
+// https://github.com/sshwy/yaoj-judger, which is unavailable when building
+// with tag "purego" or without cgo, and "purego", which is built on ptrace,
+// prlimit and polling VmHWM in /proc, and rejects policies other than
+// "builtin:free". yaoj-judger is preferred if available.
+type Backend interface {
+	Judge(option *Option) (*Result, error)
+}
+
+var backend = struct {
+	sync.RWMutex
+	registry map[string]Backend
+	current  Backend
+}{registry: map[string]Backend{}}
+
+func registerBackend(name string, b Backend) {
+	backend.Lock()
+	defer backend.Unlock()
+	backend.registry[name] = b
+}
+
+// Names of available backends.
+func Backends() []string {
+	backend.RLock()
+	defer backend.RUnlock()
+	names := []string{}
+	for name := range backend.registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set the backend used by Judge. Empty name restores the default one.
+func SetBackend(name string) error {
+	backend.Lock()
+	defer backend.Unlock()
+	if name == "" {
+		backend.current = nil
+		return nil
+	}
+	b, ok := backend.registry[name]
+	if !ok {
+		return fmt.Errorf("unknown backend %q", name)
+	}
+	backend.current = b
+	return nil
+}
+
+func currentBackend() Backend {
+	backend.RLock()
+	defer backend.RUnlock()
+	if backend.current != nil {
+		return backend.current
+	}
+	if b, ok := backend.registry["yaoj-judger"]; ok {
+		return b
+	}
+	return backend.registry["purego"]
+}
@@ -1,8 +1,15 @@
+//go:build cgo && !purego
+
 package judger
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
 	"time"
 	"unsafe"
 )
@@ -10,40 +17,87 @@ import (
 //go:generate go version
 //go:generate make -C yaoj-judger
 
-//#cgo CFLAGS: -I./yaoj-judger/include
-//#cgo LDFLAGS: -L./yaoj-judger -lyjudger
-//#include "./yaoj-judger/include/judger.h"
-//#include <stdlib.h>
+/*
+#cgo CFLAGS: -I./yaoj-judger/include
+#cgo LDFLAGS: -L./yaoj-judger -lyjudger
+#include "./yaoj-judger/include/judger.h"
+#include <stdlib.h>
+#include <string.h>
+
+typedef __typeof__(yjudger_general_fork((yjudger_ctxt_t)0)) yjudger_result_t;
+
+// failed step of a judgement
+enum {
+	YJ_DONE,
+	YJ_LOG,
+	YJ_POLICY,
+	YJ_RUNNER,
+};
+
+typedef struct {
+	int err;
+	yjudger_result_t res;
+} yjudger_report_t;
+
+// Perform a judgement with the logging state of the process, thus it's
+// called once per judger process.
+static yjudger_report_t yjudger_judge(const char *logfile, int level, int color,
+		const char *policy_dir, const char *policy, int argc, char **argv,
+		char **env, int nlimit, int *limit_type, int *limit_val, int runner) {
+	yjudger_report_t report;
+	memset(&report, 0, sizeof(report));
+
+	yjudger_ctxt_t ctxt = NULL;
+	if (log_set(logfile, level, color) != 0) {
+		report.err = YJ_LOG;
+		goto done;
+	}
+	ctxt = yjudger_ctxt_create();
+	if (yjudger_set_policy(ctxt, policy_dir, policy) != 0) {
+		report.err = YJ_POLICY;
+		goto done;
+	}
+	for (int i = 0; i < nlimit; i++) {
+		yjudger_set_limit(ctxt, limit_type[i], limit_val[i]);
+	}
+	if (yjudger_set_runner(ctxt, argc, argv, env) != 0) {
+		report.err = YJ_RUNNER;
+		goto done;
+	}
+	if (runner == 1) {
+		report.res = yjudger_interactive_fork(ctxt);
+	} else {
+		report.res = yjudger_general_fork(ctxt);
+	}
+
+done:
+	if (ctxt != NULL) yjudger_ctxt_free(ctxt);
+	log_close();
+	return report;
+}
+*/
 import "C"
 
-// StatusCode describes final status of a execution. Note that Ok is
-// the only status for success
-type StatusCode int
-
-const (
-	Ok               StatusCode = C.OK
-	RuntimeError     StatusCode = C.RE
-	MemoryExceed     StatusCode = C.MLE
-	TimeExceed       StatusCode = C.TLE
-	OoutputExceed    StatusCode = C.OLE
-	SystemError      StatusCode = C.SE
-	DangerousSyscall StatusCode = C.DSC
-	ExitError        StatusCode = C.ECE
-)
+var statusCodes = map[C.int]StatusCode{
+	C.OK:  Ok,
+	C.RE:  RuntimeError,
+	C.MLE: MemoryExceed,
+	C.TLE: TimeExceed,
+	C.OLE: OoutputExceed,
+	C.SE:  SystemError,
+	C.DSC: DangerousSyscall,
+	C.ECE: ExitError,
+}
 
-type LimitType int
-
-const (
-	realTime LimitType = C.REAL_TIME
-	cpuTime  LimitType = C.CPU_TIME
-	// virtual memory
-	virtMem  LimitType = C.VIRTUAL_MEMORY
-	realMem  LimitType = C.ACTUAL_MEMORY
-	stackMem LimitType = C.STACK_MEMORY
-	// output size
-	outputSize LimitType = C.OUTPUT_SIZE
-	filenoLim  LimitType = C.FILENO
-)
+var limitTypes = map[LimitType]C.int{
+	realTime:   C.REAL_TIME,
+	cpuTime:    C.CPU_TIME,
+	virtMem:    C.VIRTUAL_MEMORY,
+	realMem:    C.ACTUAL_MEMORY,
+	stackMem:   C.STACK_MEMORY,
+	outputSize: C.OUTPUT_SIZE,
+	filenoLim:  C.FILENO,
+}
 
 func boolToInt(v bool) int {
 	if v {
@@ -53,46 +107,37 @@ func boolToInt(v bool) int {
 	}
 }
 
-// Set logging options.
-// MUST be executed before creating context.
-//
-// filename set perform log file.
-// log_level determine minimum log level (DEBUG, INFO, WARN, ERROR = 0, 1, 2, 3)
-// with_color whether use ASCII color controller character
-func logSet(filename string, level int, color bool) error {
-	var cfilename *C.char = C.CString(filename)
-	defer C.free(unsafe.Pointer(cfilename))
-
-	res := C.log_set(cfilename, C.int(level), C.int(boolToInt(color)))
-	if res != 0 {
-		return errors.New("log_set return non zero")
-	}
-	return nil
-}
-
-// Close log file.
-func logClose() {
-	C.log_close()
-}
-
-type context struct {
-	ctxt C.yjudger_ctxt_t
+func cCharArray(a []string) []*C.char {
+	var ca []*C.char = make([]*C.char, len(a)+1)
+	for i := range a {
+		ca[i] = C.CString(a[i])
+	}
+	ca[len(ca)-1] = nil
+	return ca
 }
 
-func newContext() context {
-	return context{ctxt: C.yjudger_ctxt_create()}
+func cFreeCharArray(ca []*C.char) {
+	for _, val := range ca {
+		if val != nil {
+			C.free(unsafe.Pointer(val))
+		}
+	}
 }
 
-func (r context) Result() Result {
-	result := C.yjudger_result(r.ctxt)
+func newResult(result C.yjudger_result_t) Result {
 	signal := int(result.signal)
 	exitCode := int(result.exit_code)
 	realTime := time.Duration(int(result.real_time) * int(time.Millisecond))
 	cpuTime := time.Duration(int(result.cpu_time) * int(time.Millisecond))
 	memory := ByteValue(result.real_memory)
 
+	code, ok := statusCodes[C.int(result.code)]
+	if !ok {
+		code = SystemError
+	}
+
 	return Result{
-		Code:     StatusCode(result.code),
+		Code:     code,
 		Signal:   &signal,
 		Msg:      fmt.Sprintf("Exit with code %d", exitCode),
 		RealTime: &realTime,
@@ -101,144 +146,174 @@ func (r context) Result() Result {
 	}
 }
 
-func (r context) Free() {
-	C.yjudger_ctxt_free(r.ctxt)
-}
-
-func (r context) SetPolicy(dirname string, policy string) error {
-	var cdirname, cpolicy *C.char = C.CString(dirname), C.CString(policy)
-	defer C.free(unsafe.Pointer(cdirname))
-	defer C.free(unsafe.Pointer(cpolicy))
-
-	flag := C.yjudger_set_policy(r.ctxt, cdirname, cpolicy)
-	if flag != 0 {
-		return errors.New("set policy error")
-	}
-	return nil
+// Backend of yaoj-judger.
+type libBackend struct{}
+
+// environment variable marking a judger process, see judgerMain
+const judgerEnv = "YAOJ_JUDGER_PROCESS"
+
+// Judgement sent to a judger process through its stdin.
+type judgerRequest struct {
+	Logfile   string
+	LogLevel  int
+	LogColor  bool
+	Policy    string
+	PolicyDir string
+	Argument  []string
+	Environ   []string
+	Limit     L
+	Runner    Runner
 }
 
-func (r context) SetBuiltinPolicy(policy string) error {
-	return r.SetPolicy(".", "builtin:"+policy)
+// Report written by a judger process to fd 3.
+type judgerReport struct {
+	Result *Result
+	Err    string
 }
 
-func cCharArray(a []string) []*C.char {
-	var ca []*C.char = make([]*C.char, len(a)+1)
-	for i := range a {
-		ca[i] = C.CString(a[i])
+// Perform a judgement in a judger process, which is the executable itself
+// started again, so that concurrent calls never share logging state or
+// context of yaoj-judger, and nothing but exec runs in the child of fork.
+func (r libBackend) Judge(option *Option) (*Result, error) {
+	if option.Runner != General && option.Runner != Interactive {
+		return nil, fmt.Errorf("invalid runner")
 	}
-	ca[len(ca)-1] = nil
-	return ca
-}
-
-func cFreeCharArray(ca []*C.char) {
-	for _, val := range ca {
-		if val != nil {
-			C.free(unsafe.Pointer(val))
+	for key := range option.Limit {
+		if _, ok := limitTypes[key]; !ok {
+			return nil, fmt.Errorf("unknown limit type: %d", key)
 		}
 	}
-}
-
-func (r context) SetRunner(argv []string, env []string) error {
-	cargv, cenv := cCharArray(argv), cCharArray(env)
-	defer cFreeCharArray(cargv)
-	defer cFreeCharArray(cenv)
-
-	flag := C.yjudger_set_runner(r.ctxt, C.int(len(argv)), &cargv[0], &cenv[0])
-	if flag != 0 {
-		return errors.New("set runner error")
+	request, err := json.Marshal(judgerRequest{
+		Logfile:   option.Logfile,
+		LogLevel:  option.LogLevel,
+		LogColor:  option.LogColor,
+		Policy:    option.Policy,
+		PolicyDir: option.PolicyDir,
+		Argument:  option.Argument,
+		Environ:   option.Environ,
+		Limit:     option.Limit,
+		Runner:    option.Runner,
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
-
-type Runner int
 
-// Runner type
-const (
-	General     Runner = 0
-	Interactive Runner = 1
-)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// the judger process leads its own process group, which is killed once
+	// the context is done
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Dir = option.Dir
+	cmd.Env = append(os.Environ(), judgerEnv+"=1")
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{writer}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err = cmd.Start()
+	writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("start judger process: %w", err)
+	}
 
-func (r context) Run(runner Runner) error {
-	var flag C.int
-	switch runner {
-	case General:
-		flag = C.yjudger_general(r.ctxt)
-	case Interactive:
-		flag = C.yjudger_interactive(r.ctxt)
-	default:
-		return errors.New("unknown runner: " + fmt.Sprint(runner))
+	// It is not reaped until the watcher stops.
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+		case <-option.context().Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			cmd.Process.Kill()
+		}
+	}()
+	var report judgerReport
+	readErr := json.NewDecoder(reader).Decode(&report)
+	close(done)
+	<-stopped
+	waitErr := cmd.Wait()
+
+	if err := option.context().Err(); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("judger process exit abnormally: %v", waitErr)
 	}
-	if flag != 0 {
-		return errors.New("perform general error")
+	if report.Err != "" {
+		return nil, errors.New(report.Err)
 	}
-	return nil
+	return report.Result, nil
 }
 
-func (r context) RunForkGeneral() Result {
-	result := C.yjudger_general_fork(r.ctxt)
-	signal := int(result.signal)
-	exitCode := int(result.exit_code)
-	realTime := time.Duration(int(result.real_time) * int(time.Millisecond))
-	cpuTime := time.Duration(int(result.cpu_time) * int(time.Millisecond))
-	memory := ByteValue(result.real_memory)
-
-	return Result{
-		Code:     StatusCode(result.code),
-		Signal:   &signal,
-		Msg:      fmt.Sprintf("Exit with code %d", exitCode),
-		RealTime: &realTime,
-		CpuTime:  &cpuTime,
-		Memory:   &memory,
+// Perform the judgement of request.
+func (r judgerRequest) judge() (*Result, error) {
+	limitType, limitVal := []C.int{}, []C.int{}
+	for key, val := range r.Limit {
+		limitType = append(limitType, limitTypes[key])
+		limitVal = append(limitVal, C.int(val))
+	}
+	var ptype, pval *C.int
+	if len(limitType) > 0 {
+		ptype, pval = &limitType[0], &limitVal[0]
 	}
-}
 
-func (r context) RunForkInteractive() Result {
-	result := C.yjudger_interactive_fork(r.ctxt)
-	signal := int(result.signal)
-	exitCode := int(result.exit_code)
-	realTime := time.Duration(int(result.real_time) * int(time.Millisecond))
-	cpuTime := time.Duration(int(result.cpu_time) * int(time.Millisecond))
-	memory := ByteValue(result.real_memory)
+	clogfile := C.CString(r.Logfile)
+	defer C.free(unsafe.Pointer(clogfile))
+	cpolicydir, cpolicy := C.CString(r.PolicyDir), C.CString(r.Policy)
+	defer C.free(unsafe.Pointer(cpolicydir))
+	defer C.free(unsafe.Pointer(cpolicy))
+	cargv, cenv := cCharArray(r.Argument), cCharArray(r.Environ)
+	defer cFreeCharArray(cargv)
+	defer cFreeCharArray(cenv)
 
-	return Result{
-		Code:     StatusCode(result.code),
-		Signal:   &signal,
-		Msg:      fmt.Sprintf("Exit with code %d", exitCode),
-		RealTime: &realTime,
-		CpuTime:  &cpuTime,
-		Memory:   &memory,
+	report := C.yjudger_judge(clogfile, C.int(r.LogLevel), C.int(boolToInt(r.LogColor)),
+		cpolicydir, cpolicy, C.int(len(r.Argument)), &cargv[0], &cenv[0],
+		C.int(len(limitType)), ptype, pval, C.int(r.Runner))
+
+	switch report.err {
+	case C.YJ_DONE:
+		result := newResult(report.res)
+		return &result, nil
+	case C.YJ_LOG:
+		return nil, errors.New("log_set return non zero")
+	case C.YJ_POLICY:
+		return nil, errors.New("set policy error")
+	case C.YJ_RUNNER:
+		return nil, errors.New("set runner error")
+	default:
+		return nil, fmt.Errorf("unknown judger error: %d", report.err)
 	}
 }
 
-// short cut for Limitation
-type L map[LimitType]int64
-
-func (r context) SetLimit(options L) error {
-	for key, val := range options {
-		switch key {
-		case realTime:
-			C.yjudger_set_limit(r.ctxt, C.REAL_TIME, C.int(val))
-		case cpuTime:
-			C.yjudger_set_limit(r.ctxt, C.CPU_TIME, C.int(val))
-		case virtMem:
-			C.yjudger_set_limit(r.ctxt, C.VIRTUAL_MEMORY, C.int(val))
-		case realMem:
-			C.yjudger_set_limit(r.ctxt, C.ACTUAL_MEMORY, C.int(val))
-		case stackMem:
-			C.yjudger_set_limit(r.ctxt, C.STACK_MEMORY, C.int(val))
-		case outputSize:
-			C.yjudger_set_limit(r.ctxt, C.OUTPUT_SIZE, C.int(val))
-		case filenoLim:
-			C.yjudger_set_limit(r.ctxt, C.FILENO, C.int(val))
-		default:
-			return fmt.Errorf("unknown limit type: %d", key)
-		}
+// Entry of a judger process started by libBackend.Judge: the judgement read
+// from stdin is performed and reported to fd 3, then the process exits.
+func judgerMain() {
+	var request judgerRequest
+	var report judgerReport
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		report.Err = fmt.Sprintf("decode request: %v", err)
+	} else if report.Result, err = request.judge(); err != nil {
+		report.Err = err.Error()
+	}
+	if err := json.NewEncoder(os.NewFile(3, "report")).Encode(report); err != nil {
+		os.Exit(1)
 	}
-	return nil
+	os.Exit(0)
 }
 
+var _ Backend = libBackend{}
+
+// A judger process performs its judgement before other packages importing
+// this one are initialized.
 func init() {
 	if code := C.log_init(); code != 0 {
 		panic(fmt.Sprint("init log failed: ", code))
 	}
+	if os.Getenv(judgerEnv) != "" {
+		judgerMain()
+	}
+	registerBackend("yaoj-judger", libBackend{})
 }
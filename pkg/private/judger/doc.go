@@ -8,5 +8,26 @@ To build (run) this package, you need to make sure auditd, flex, make,
 gengetopt, bison, xxd, strace, and clang toolkit (basically clang++) is
 available via command line. If not, install them.  Before building, run go
 generate for some necessary files.
+
+Every judgement of "yaoj-judger" is performed in a judger process, which is
+the executable started again (via /proc/self/exe) with an environment
+variable telling the init of this package to judge and exit. It owns its
+logging state and context, thus Judge is safe to be called concurrently. Use
+Pool (or SetPool) to limit the number of sandboxes running at the same time.
+
+Judgements are dispatched to a Backend. Two backends are provided: the default
+"yaoj-judger" (cgo), and "purego", which rejects policies other than
+"builtin:free" with SystemError. Building without cgo, or with the "purego"
+build tag, leaves only the latter. SetBackend selects one at runtime.
+
+The purego backend starts programs with os/exec and collects CPU time with
+wait4 rusage, but it can not call setrlimit in the child before execve, and
+calling it in the parent would limit concurrent judgements as well. Thus the
+child is traced, stopped right after execve, and limited with prlimit before
+it runs. RLIMIT_AS only limits virtual memory, so VmHWM in /proc is polled to
+kill the process once it exceeds the memory limit, with ru_maxrss as a
+fallback of the peak memory. For the Interactive runner, limits apply to the
+executable only, while the interactor, given by the problem, is limited by
+real time only, thus it's killed when the judgement takes too long.
 */
 package judger
@@ -1,9 +1,11 @@
 package judger
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"sync"
+	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/sshwy/yaoj-core/pkg/processor"
@@ -16,10 +18,14 @@ type Option struct {
 	LogColor  bool
 	Policy    string
 	PolicyDir string
-	Argument  []string
-	Environ   []string
-	Limit     L
-	Runner    Runner
+	// working directory of the executable, empty for the current one
+	Dir      string
+	Argument []string
+	Environ  []string
+	Limit    L
+	Runner   Runner
+	// the judgement is killed once it is done, nil for never
+	Context context.Context
 }
 
 type OptionProvider func(*Option)
@@ -73,12 +79,15 @@ func (r *Result) ProcResult() *processor.Result {
 	return &res
 }
 
-var judgeSync sync.Mutex
-
-func Judge(options ...OptionProvider) (*Result, error) {
-	judgeSync.Lock()
-	defer judgeSync.Unlock()
+// context of the judgement, never nil
+func (r *Option) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
 
+func newOption(options ...OptionProvider) *Option {
 	var option = Option{
 		Environ:   os.Environ(),
 		Policy:    "builtin:free",
@@ -93,37 +102,62 @@ func Judge(options ...OptionProvider) (*Result, error) {
 	for _, v := range options {
 		v(&option)
 	}
+	return &option
+}
 
-	if err := logSet(option.Logfile, option.LogLevel, option.LogColor); err != nil {
-		return nil, err
+// Judge is safe to be called concurrently. If a pool is set by SetPool, it
+// limits the number of parallel judgements.
+func Judge(options ...OptionProvider) (*Result, error) {
+	if pool, _ := defaultPool.Load().(*Pool); pool != nil {
+		return pool.Judge(options...)
 	}
-	defer logClose()
-
-	ctxt := newContext()
-	defer ctxt.Free()
+	return judge(newOption(options...))
+}
 
-	if err := ctxt.SetPolicy(option.PolicyDir, option.Policy); err != nil {
+func judge(option *Option) (*Result, error) {
+	if err := option.context().Err(); err != nil {
 		return nil, err
 	}
+	return currentBackend().Judge(option)
+}
 
-	if err := ctxt.SetLimit(option.Limit); err != nil {
-		return nil, err
-	}
+// Same as Judge, but the sandboxed process is killed and ctx.Err() is
+// returned once ctx is done.
+func JudgeContext(ctx context.Context, options ...OptionProvider) (*Result, error) {
+	return Judge(append(options, WithContext(ctx))...)
+}
 
-	if err := ctxt.SetRunner(option.Argument, option.Environ); err != nil {
-		return nil, err
+// Pool limits the number of sandboxes running at the same time.
+type Pool struct {
+	sem chan struct{}
+}
+
+// Create a pool running at most n judgements at the same time.
+// n <= 0 means runtime.NumCPU().
+func NewPool(n int) *Pool {
+	if n <= 0 {
+		n = runtime.NumCPU()
 	}
+	return &Pool{sem: make(chan struct{}, n)}
+}
 
-	var result Result
-	switch option.Runner {
-	case General:
-		result = ctxt.RunForkGeneral()
-	case Interactive:
-		result = ctxt.RunForkInteractive()
-	default:
-		return nil, fmt.Errorf("invalid runner")
+// Same as Judge, but blocks until the pool has a free slot.
+func (r *Pool) Judge(options ...OptionProvider) (*Result, error) {
+	option := newOption(options...)
+	select {
+	case r.sem <- struct{}{}:
+	case <-option.context().Done():
+		return nil, option.context().Err()
 	}
-	return &result, nil
+	defer func() { <-r.sem }()
+	return judge(option)
+}
+
+var defaultPool atomic.Value
+
+// Set the pool used by Judge. nil means no limitation (default).
+func SetPool(pool *Pool) {
+	defaultPool.Store(pool)
 }
 
 // Runners differ in arguments.
@@ -167,6 +201,14 @@ func WithPolicyDir(dir string) OptionProvider {
 	}
 }
 
+// Set working directory of the executable. Relative paths in arguments are
+// resolved in it as well.
+func WithDir(dir string) OptionProvider {
+	return func(o *Option) {
+		o.Dir = dir
+	}
+}
+
 // Set real time limitation
 func WithRealTime(duration time.Duration) OptionProvider {
 	return func(o *Option) {
@@ -212,6 +254,13 @@ func WithFileno(num int) OptionProvider {
 	}
 }
 
+// Kill the judgement once ctx is done, in which case ctx.Err() is returned.
+func WithContext(ctx context.Context) OptionProvider {
+	return func(o *Option) {
+		o.Context = ctx
+	}
+}
+
 // Set logging file. Default is "runtime.log".
 func WithLog(file string, level int, color bool) OptionProvider {
 	return func(o *Option) {
@@ -1,7 +1,11 @@
 package judger_test
 
 import (
+	"fmt"
+	"os"
 	"path"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,3 +33,74 @@ func TestJudge(t *testing.T) {
 	}
 	t.Log(*res)
 }
+
+func TestJudgeConcurrent(t *testing.T) {
+	for _, name := range judger.Backends() {
+		t.Run(name, func(t *testing.T) {
+			if err := judger.SetBackend(name); err != nil {
+				t.Fatal(err)
+			}
+			defer judger.SetBackend("")
+			testJudgeConcurrent(t)
+		})
+	}
+}
+
+// each job prints its working directory and when it starts and ends
+func testJudgeConcurrent(t *testing.T) {
+	const n, limit = 4, 2
+	dir := t.TempDir()
+	pool := judger.NewPool(limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if err := os.Mkdir(path.Join(dir, fmt.Sprint("job", i)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := pool.Judge(
+				judger.WithArgument("/dev/null", path.Join(dir, fmt.Sprint("output", i)), "/dev/null",
+					"/bin/sh", "-c", "pwd; date +%s%N; sleep 0.3; date +%s%N"),
+				judger.WithJudger(judger.General),
+				judger.WithPolicy("builtin:free"),
+				judger.WithDir(path.Join(dir, fmt.Sprint("job", i))),
+				judger.WithLog(path.Join(dir, fmt.Sprint("runtime", i, ".log")), 0, false),
+				judger.WithRealTime(time.Millisecond*3000),
+			)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			t.Log(*res)
+		}(i)
+	}
+	wg.Wait()
+
+	var begin, end [n]int64
+	for i := 0; i < n; i++ {
+		output, err := os.ReadFile(path.Join(dir, fmt.Sprint("output", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cwd string
+		if _, err := fmt.Sscan(string(output), &cwd, &begin[i], &end[i]); err != nil {
+			t.Fatalf("job %d: unexpected output %q", i, output)
+		}
+		want, _ := filepath.EvalSymlinks(path.Join(dir, fmt.Sprint("job", i)))
+		if got, _ := filepath.EvalSymlinks(cwd); got != want {
+			t.Errorf("job %d: expect working directory %s, found %s", i, want, cwd)
+		}
+	}
+	for i := 0; i < n; i++ {
+		running := 0
+		for j := 0; j < n; j++ {
+			if begin[j] <= begin[i] && begin[i] < end[j] {
+				running++
+			}
+		}
+		if running > limit {
+			t.Errorf("%d jobs running at the same time, expect at most %d", running, limit)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+//go:build linux
+
+package judger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// PTRACE_O_EXITKILL, missing in package syscall
+const ptraceOExitKill = 0x100000
+
+// Backend implemented in pure go: the process is stopped by ptrace right after
+// execve, limited by prlimit, and its peak memory is polled from VmHWM in
+// /proc. Policies other than "builtin:free" can not be enforced, thus are
+// rejected with SystemError. For the Interactive runner, limits apply to the
+// executable, while the interactor is only limited by real time.
+type pureBackend struct{}
+
+func (r pureBackend) Judge(option *Option) (*Result, error) {
+	logfile, err := os.Create(resolvePath(option.Dir, option.Logfile))
+	if err != nil {
+		return nil, err
+	}
+	defer logfile.Close()
+	logger := log.New(logfile, "[purego] ", log.LstdFlags|log.Lmsgprefix)
+	if option.Policy != "builtin:free" {
+		logger.Printf("policy %q is not supported", option.Policy)
+		return &Result{
+			Code: SystemError,
+			Msg:  fmt.Sprintf("policy %q is not supported by purego backend", option.Policy),
+		}, nil
+	}
+
+	switch option.Runner {
+	case General:
+		return r.general(option, logger)
+	case Interactive:
+		return r.interactive(option, logger)
+	default:
+		return nil, fmt.Errorf("invalid runner")
+	}
+}
+
+// [input] [output] [outerr] [exec] [arguments...]
+func (r pureBackend) general(option *Option, logger *log.Logger) (*Result, error) {
+	if len(option.Argument) < 4 {
+		return nil, fmt.Errorf("invalid argument: %q", option.Argument)
+	}
+	arg := option.Argument
+	stdin, err := os.Open(resolvePath(option.Dir, arg[0]))
+	if err != nil {
+		return nil, err
+	}
+	defer stdin.Close()
+	stdout, err := createFile(resolvePath(option.Dir, arg[1]))
+	if err != nil {
+		return nil, err
+	}
+	defer stdout.Close()
+	stderr, err := createFile(resolvePath(option.Dir, arg[2]))
+	if err != nil {
+		return nil, err
+	}
+	defer stderr.Close()
+
+	sb := sandbox{
+		argv:   arg[3:],
+		env:    option.Environ,
+		dir:    option.Dir,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		limit:  option.Limit,
+		ctx:    option.context(),
+	}
+	logger.Printf("run %q", sb.argv)
+	use, err := sb.run()
+	if err == nil {
+		err = option.context().Err()
+	}
+	if err != nil {
+		logger.Printf("error: %v", err)
+		return nil, err
+	}
+	result := use.result(option.Limit)
+	logger.Printf("result: %v", result)
+	return &result, nil
+}
+
+// [exec] [interactor] [input_itct] [output_itct] [outerr_itct] [outerr]
+func (r pureBackend) interactive(option *Option, logger *log.Logger) (*Result, error) {
+	if len(option.Argument) != 6 {
+		return nil, fmt.Errorf("invalid argument: %q", option.Argument)
+	}
+	arg := option.Argument
+	outerr, err := createFile(resolvePath(option.Dir, arg[5]))
+	if err != nil {
+		return nil, err
+	}
+	defer outerr.Close()
+	itcterr, err := createFile(resolvePath(option.Dir, arg[4]))
+	if err != nil {
+		return nil, err
+	}
+	defer itcterr.Close()
+	// interactor -> executable
+	r1, w1, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// executable -> interactor
+	r2, w2, err := os.Pipe()
+	if err != nil {
+		r1.Close()
+		w1.Close()
+		return nil, err
+	}
+
+	itctLimit := L{}
+	if lim, ok := option.Limit[realTime]; ok {
+		itctLimit[realTime] = lim
+	}
+	sbs := [2]sandbox{{
+		argv:            arg[0:1],
+		env:             option.Environ,
+		dir:             option.Dir,
+		stdin:           r1,
+		stdout:          w2,
+		stderr:          outerr,
+		limit:           option.Limit,
+		closeAfterStart: []*os.File{r1, w2},
+		ctx:             option.context(),
+	}, {
+		argv:            []string{arg[1], arg[2], arg[3]},
+		env:             option.Environ,
+		dir:             option.Dir,
+		stdin:           r2,
+		stdout:          w1,
+		stderr:          itcterr,
+		limit:           itctLimit,
+		closeAfterStart: []*os.File{r2, w1},
+		ctx:             option.context(),
+	}}
+	logger.Printf("run %q interacting with %q", sbs[0].argv, sbs[1].argv)
+
+	var uses [2]*usage
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i := range sbs {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uses[i], errs[i] = sbs[i].run()
+		}()
+	}
+	wg.Wait()
+	for _, err := range append(errs[:], option.context().Err()) {
+		if err != nil {
+			logger.Printf("error: %v", err)
+			return nil, err
+		}
+	}
+
+	result := uses[0].result(option.Limit)
+	itctResult := uses[1].result(itctLimit)
+	logger.Printf("result: %v, interactor: %v", result, itctResult)
+	// the executable may be killed by SIGPIPE after interactor quits
+	brokenPipe := result.Code == RuntimeError && *result.Signal == int(syscall.SIGPIPE)
+	if itctResult.Code != Ok && (result.Code == Ok || brokenPipe) {
+		itctResult.Msg = "interactor: " + itctResult.Msg
+		return &itctResult, nil
+	}
+	return &result, nil
+}
+
+// A process executed under limitation.
+type sandbox struct {
+	argv                  []string
+	env                   []string
+	dir                   string
+	stdin, stdout, stderr *os.File
+	limit                 L
+	// closed once the process is started
+	closeAfterStart []*os.File
+	// the process group is killed once it is done
+	ctx context.Context
+}
+
+type usage struct {
+	status   syscall.WaitStatus
+	rusage   syscall.Rusage
+	realTime time.Duration
+	// peak resident set size
+	memory ByteValue
+	// killed for exceeding real time (memory) limit
+	timeout, memoryOut bool
+}
+
+// Execute the program traced, so that it is stopped right after execve
+// and limitations are set before it runs.
+func (r *sandbox) run() (*usage, error) {
+	path, err := lookPath(r.dir, r.argv[0])
+	if err != nil {
+		for _, file := range r.closeAfterStart {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	// ptrace requests must come from the thread starting the tracee
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   r.argv,
+		Env:    r.env,
+		Dir:    r.dir,
+		Stdin:  r.stdin,
+		Stdout: r.stdout,
+		Stderr: r.stderr,
+		SysProcAttr: &syscall.SysProcAttr{
+			Ptrace:  true,
+			Setpgid: true,
+		},
+	}
+	begin := time.Now()
+	err = cmd.Start()
+	for _, file := range r.closeAfterStart {
+		file.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cmd.Process.Release()
+	pid := cmd.Process.Pid
+
+	var res usage
+	if err := wait4(pid, &res.status, &res.rusage); err != nil {
+		return nil, err
+	}
+	if !res.status.Stopped() {
+		res.realTime = time.Since(begin)
+		return &res, nil
+	}
+	if err := r.setRlimit(pid); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		wait4(pid, &res.status, &res.rusage)
+		return nil, err
+	}
+	if err := syscall.PtraceSetOptions(pid, syscall.PTRACE_O_TRACEEXIT|ptraceOExitKill); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		wait4(pid, &res.status, &res.rusage)
+		return nil, err
+	}
+
+	var peak int64
+	var timeout, memoryOut int32
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.ctx.Done():
+				syscall.Kill(-pid, syscall.SIGKILL)
+				return
+			case <-ticker.C:
+			}
+			mem := peakMemory(pid)
+			if mem > atomic.LoadInt64(&peak) {
+				atomic.StoreInt64(&peak, mem)
+			}
+			if lim, ok := r.limit[realMem]; ok && mem > lim {
+				atomic.StoreInt32(&memoryOut, 1)
+				syscall.Kill(-pid, syscall.SIGKILL)
+				return
+			}
+			if lim, ok := r.limit[realTime]; ok && time.Since(begin) > time.Duration(lim)*time.Millisecond {
+				atomic.StoreInt32(&timeout, 1)
+				syscall.Kill(-pid, syscall.SIGKILL)
+				return
+			}
+		}
+	}()
+
+	sig := 0
+	for {
+		if err := syscall.PtraceCont(pid, sig); err != nil && err != syscall.ESRCH {
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+		if err := wait4(pid, &res.status, &res.rusage); err != nil {
+			close(done)
+			wg.Wait()
+			return nil, err
+		}
+		if res.status.Exited() || res.status.Signaled() {
+			break
+		}
+		sig = int(res.status.StopSignal())
+		if res.status.StopSignal() == syscall.SIGTRAP {
+			if res.status.TrapCause() == syscall.PTRACE_EVENT_EXIT {
+				// memory is still mapped when exiting
+				if mem := peakMemory(pid); mem > atomic.LoadInt64(&peak) {
+					atomic.StoreInt64(&peak, mem)
+				}
+			}
+			sig = 0
+		}
+	}
+	res.realTime = time.Since(begin)
+	close(done)
+	wg.Wait()
+
+	res.memory = ByteValue(atomic.LoadInt64(&peak))
+	if res.memory == 0 {
+		res.memory = ByteValue(res.rusage.Maxrss) * KB
+	}
+	res.timeout = atomic.LoadInt32(&timeout) == 1
+	res.memoryOut = atomic.LoadInt32(&memoryOut) == 1
+	return &res, nil
+}
+
+func (r *sandbox) setRlimit(pid int) error {
+	set := func(resource int, cur, max uint64) error {
+		lim := syscall.Rlimit{Cur: cur, Max: max}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+			uintptr(unsafe.Pointer(&lim)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("prlimit %d: %w", resource, errno)
+		}
+		return nil
+	}
+	for key, val := range r.limit {
+		var err error
+		switch key {
+		case cpuTime:
+			sec := uint64((val + 999) / 1000)
+			err = set(syscall.RLIMIT_CPU, sec, sec+1)
+		case virtMem:
+			err = set(syscall.RLIMIT_AS, uint64(val), uint64(val))
+		case stackMem:
+			err = set(syscall.RLIMIT_STACK, uint64(val), uint64(val))
+		case outputSize:
+			err = set(syscall.RLIMIT_FSIZE, uint64(val), uint64(val))
+		case filenoLim:
+			err = set(syscall.RLIMIT_NOFILE, uint64(val), uint64(val))
+		case realTime, realMem: // watched while running
+		default:
+			err = fmt.Errorf("unknown limit type: %d", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *usage) result(limit L) Result {
+	realDuration := r.realTime
+	cpuDuration := time.Duration(r.rusage.Utime.Nano() + r.rusage.Stime.Nano())
+	memory := r.memory
+	signal, exitCode := 0, 0
+
+	code := Ok
+	if r.status.Signaled() {
+		signal = int(r.status.Signal())
+	} else {
+		exitCode = r.status.ExitStatus()
+	}
+	lim, hasCpuLim := limit[cpuTime]
+	switch {
+	case r.timeout:
+		code = TimeExceed
+	case r.memoryOut:
+		code = MemoryExceed
+	case hasCpuLim && cpuDuration > time.Duration(lim)*time.Millisecond:
+		code = TimeExceed
+	case limit[realMem] > 0 && int64(memory) > limit[realMem]:
+		code = MemoryExceed
+	case signal == int(syscall.SIGXCPU):
+		code = TimeExceed
+	case signal == int(syscall.SIGXFSZ):
+		code = OoutputExceed
+	case signal != 0:
+		code = RuntimeError
+	case exitCode != 0:
+		code = ExitError
+	}
+
+	return Result{
+		Code:     code,
+		Signal:   &signal,
+		Msg:      fmt.Sprintf("Exit with code %d", exitCode),
+		RealTime: &realDuration,
+		CpuTime:  &cpuDuration,
+		Memory:   &memory,
+	}
+}
+
+// VmHWM of the process in bytes, 0 if unavailable.
+func peakMemory(pid int) int64 {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "VmHWM:") {
+			continue
+		}
+		fields := strings.Fields(line[len("VmHWM:"):])
+		if len(fields) == 0 {
+			return 0
+		}
+		kb, _ := strconv.ParseInt(fields[0], 10, 64)
+		return kb * int64(KB)
+	}
+	return 0
+}
+
+func wait4(pid int, status *syscall.WaitStatus, rusage *syscall.Rusage) error {
+	for {
+		_, err := syscall.Wait4(pid, status, 0, rusage)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// Relative paths are resolved in dir.
+func resolvePath(dir string, name string) string {
+	if dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+func lookPath(dir string, name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return resolvePath(dir, name), nil
+	}
+	return exec.LookPath(name)
+}
+
+func createFile(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+var _ Backend = pureBackend{}
+
+func init() {
+	registerBackend("purego", pureBackend{})
+}
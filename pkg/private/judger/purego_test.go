@@ -0,0 +1,149 @@
+package judger_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/private/judger"
+)
+
+func TestPuregoBackend(t *testing.T) {
+	if err := judger.SetBackend("purego"); err != nil {
+		t.Fatal(err)
+	}
+	defer judger.SetBackend("")
+
+	dir := t.TempDir()
+	judge := func(limit []judger.OptionProvider, argv ...string) *judger.Result {
+		options := []judger.OptionProvider{
+			judger.WithArgument(append([]string{"/dev/null", path.Join(dir, "output"), "/dev/null"}, argv...)...),
+			judger.WithJudger(judger.General),
+			judger.WithLog(path.Join(dir, "runtime.log"), 0, false),
+		}
+		res, err := judger.Judge(append(options, limit...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(*res)
+		return res
+	}
+
+	t.Run("Ok", func(t *testing.T) {
+		res := judge(nil, "/bin/sh", "-c", "echo hello")
+		if res.Code != judger.Ok {
+			t.Errorf("expect %v, found %v", judger.Ok, res.Code)
+		}
+		output, _ := os.ReadFile(path.Join(dir, "output"))
+		if strings.TrimSpace(string(output)) != "hello" {
+			t.Errorf("unexpected output %q", output)
+		}
+	})
+	t.Run("ExitError", func(t *testing.T) {
+		res := judge(nil, "/bin/sh", "-c", "exit 3")
+		if res.Code != judger.ExitError {
+			t.Errorf("expect %v, found %v", judger.ExitError, res.Code)
+		}
+	})
+	t.Run("CpuTime", func(t *testing.T) {
+		res := judge([]judger.OptionProvider{judger.WithCpuTime(500 * time.Millisecond)},
+			"/bin/sh", "-c", "while :; do :; done")
+		if res.Code != judger.TimeExceed {
+			t.Errorf("expect %v, found %v", judger.TimeExceed, res.Code)
+		}
+	})
+	t.Run("RealTime", func(t *testing.T) {
+		res := judge([]judger.OptionProvider{judger.WithRealTime(300 * time.Millisecond)},
+			"/bin/sleep", "10")
+		if res.Code != judger.TimeExceed {
+			t.Errorf("expect %v, found %v", judger.TimeExceed, res.Code)
+		}
+	})
+	t.Run("Output", func(t *testing.T) {
+		res := judge([]judger.OptionProvider{judger.WithOutput(judger.KB)},
+			"/bin/sh", "-c", "head -c 100000 /dev/zero")
+		if res.Code == judger.Ok {
+			t.Errorf("expect output limit exceeded, found %v", res.Code)
+		}
+	})
+	t.Run("Policy", func(t *testing.T) {
+		res := judge([]judger.OptionProvider{judger.WithPolicy("builtin:c_cpp")}, "/bin/sh", "-c", "echo hello")
+		if res.Code != judger.SystemError {
+			t.Errorf("expect %v for unsupported policy, found %v", judger.SystemError, res.Code)
+		}
+	})
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		begin := time.Now()
+		_, err := judger.JudgeContext(ctx,
+			judger.WithArgument("/dev/null", "/dev/null", "/dev/null", "/bin/sleep", "10"),
+			judger.WithJudger(judger.General),
+			judger.WithLog(path.Join(dir, "runtime.log"), 0, false),
+		)
+		if err != context.DeadlineExceeded {
+			t.Errorf("expect %v, found %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(begin) > 5*time.Second {
+			t.Errorf("process not killed")
+		}
+	})
+	t.Run("Interactive", func(t *testing.T) {
+		script := func(name string, content string) string {
+			name = path.Join(dir, name)
+			if err := os.WriteFile(name, []byte(content), 0744); err != nil {
+				t.Fatal(err)
+			}
+			return name
+		}
+		interactor := script("interactor.sh", "#!/bin/sh\nread n < \"$1\"\necho $n\nread x\n"+
+			"if [ \"$x\" = \"$((n*2))\" ]; then echo ok > \"$2\"; exit 0; fi\necho wrong > \"$2\"\nexit 1\n")
+		input := script("itct.in", "5\n")
+		for exec, code := range map[string]judger.StatusCode{
+			"#!/bin/sh\nread x\necho $((x*2))\n": judger.Ok,
+			"#!/bin/sh\nread x\necho $((x+2))\n": judger.ExitError,
+		} {
+			res, err := judger.Judge(
+				judger.WithArgument(script("exec.sh", exec), interactor, input,
+					path.Join(dir, "itct.out"), path.Join(dir, "itct.err"), "/dev/null"),
+				judger.WithJudger(judger.Interactive),
+				judger.WithLog(path.Join(dir, "runtime.log"), 0, false),
+				judger.WithRealTime(time.Second),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Log(*res)
+			if res.Code != code {
+				t.Errorf("expect %v, found %v", code, res.Code)
+			}
+		}
+
+		// the interactor is limited by real time only
+		exec := script("exec.sh", "#!/bin/sh\nread x\necho $((x*2))\n")
+		for itct, code := range map[string]judger.StatusCode{
+			"#!/bin/sh\nend=$(($(date +%s%N)+500000000))\nwhile [ $(date +%s%N) -lt $end ]; do :; done\n" +
+				"read n < \"$1\"\necho $n\nread x\necho ok > \"$2\"\n": judger.Ok,
+			"#!/bin/sh\nsleep 10\n": judger.TimeExceed,
+		} {
+			res, err := judger.Judge(
+				judger.WithArgument(exec, script("interactor.sh", itct), input,
+					path.Join(dir, "itct.out"), path.Join(dir, "itct.err"), "/dev/null"),
+				judger.WithJudger(judger.Interactive),
+				judger.WithLog(path.Join(dir, "runtime.log"), 0, false),
+				judger.WithCpuTime(200*time.Millisecond),
+				judger.WithRealTime(2*time.Second),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Log(*res)
+			if res.Code != code {
+				t.Errorf("interactor limited: expect %v, found %v", code, res.Code)
+			}
+		}
+	})
+}
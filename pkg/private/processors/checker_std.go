@@ -0,0 +1,329 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+)
+
+// Go implementation of standard testlib checkers (see
+// pkg/migrator/asserts/checker for the original ones). Name is one of wcmp,
+// lcmp, ncmp, rcmp4, rcmp6, rcmp9, yesno, nyesno, fcmp, uncmp and icmp.
+// Outputs are the same as CheckerTestlib's, except that there is no judgerlog.
+type CheckerStd struct {
+	Name string
+	// input: input, output, answer
+	// output: xmlreport, stderr
+}
+
+func (r CheckerStd) Label() (inputlabel []string, outputlabel []string) {
+	return []string{"input", "output", "answer"}, []string{"xmlreport", "stderr"}
+}
+
+func (r CheckerStd) Run(input []string, output []string) *Result {
+	check, ok := stdCheckers[r.Name]
+	if !ok {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  fmt.Sprintf("unknown checker %q", r.Name),
+		}
+	}
+	var streams [3]*stream
+	for i, name := range input {
+		file, err := os.Open(name)
+		if err != nil {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "open: " + err.Error(),
+			}
+		}
+		defer file.Close()
+		streams[i] = newStream(file, i == 1)
+	}
+
+	res := runChecker(check, streams[0], streams[1], streams[2])
+	if err := writeXmlReport(output[0], res); err != nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  "write report: " + err.Error(),
+		}
+	}
+	if err := os.WriteFile(output[1], []byte(res.verdict.errorName()+res.msg+"\n"), 0644); err != nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  "write stderr: " + err.Error(),
+		}
+	}
+	if res.verdict != vOk {
+		return &Result{
+			Code: processor.ExitError,
+			Msg:  fmt.Sprintf("Exit with code %d", res.verdict.exitCode()),
+		}
+	}
+	return &Result{
+		Code: processor.Ok,
+		Msg:  "Exit with code 0",
+	}
+}
+
+var _ Processor = CheckerStd{}
+
+var stdCheckers = map[string]func(inf, ouf, ans *stream){
+	"wcmp":   checkWcmp,
+	"lcmp":   checkLcmp,
+	"ncmp":   checkNcmp,
+	"rcmp4":  checkRcmp(1e-4, 5),
+	"rcmp6":  checkRcmp(1e-6, 7),
+	"rcmp9":  checkRcmp(1e-9, 10),
+	"yesno":  checkYesno,
+	"nyesno": checkNyesno,
+	"fcmp":   checkFcmp,
+	"uncmp":  checkUncmp,
+	"icmp":   checkIcmp,
+}
+
+// compare sequences of tokens
+func checkWcmp(inf, ouf, ans *stream) {
+	n := 0
+	var j, p string
+	for !ans.seekEof() && !ouf.seekEof() {
+		n++
+		j, p = ans.readWord(), ouf.readWord()
+		if j != p {
+			quitf(vWa, "%d%s words differ - expected: '%s', found: '%s'", n, englishEnding(n), compress(j), compress(p))
+		}
+	}
+	if ans.seekEof() && ouf.seekEof() {
+		if n == 1 {
+			quitf(vOk, "\"%s\"", compress(j))
+		}
+		quitf(vOk, "%d tokens", n)
+	}
+	if ans.seekEof() {
+		quitf(vWa, "Participant output contains extra tokens")
+	}
+	quitf(vWa, "Unexpected EOF in the participants output")
+}
+
+// compare files as sequence of lines, where lines are compared by compare
+func checkLines(ouf, ans *stream, compare func(j, p string) bool, lastAnswer bool) {
+	n := 0
+	var str string
+	for !ans.eof() {
+		j := ans.readLine()
+		if j == "" && ans.eof() {
+			break
+		}
+		p := ouf.readLine()
+		if lastAnswer {
+			str = j
+		} else {
+			str = p
+		}
+		n++
+		if !compare(j, p) {
+			quitf(vWa, "%d%s lines differ - expected: '%s', found: '%s'", n, englishEnding(n), compress(j), compress(p))
+		}
+	}
+	if n == 1 {
+		quitf(vOk, "single line: '%s'", compress(str))
+	}
+	quitf(vOk, "%d lines", n)
+}
+
+// compare files as sequence of tokens in lines
+func checkLcmp(inf, ouf, ans *stream) {
+	checkLines(ouf, ans, func(j, p string) bool {
+		return strings.Join(strings.Fields(j), " ") == strings.Join(strings.Fields(p), " ")
+	}, false)
+}
+
+// compare files as sequence of lines
+func checkFcmp(inf, ouf, ans *stream) {
+	checkLines(ouf, ans, func(j, p string) bool { return j == p }, true)
+}
+
+// count the remaining tokens
+func countTokens(s *stream) int {
+	count := 0
+	for !s.seekEof() {
+		s.readWord()
+		count++
+	}
+	return count
+}
+
+// compare ordered sequences of signed int64 numbers
+func checkNcmp(inf, ouf, ans *stream) {
+	n := 0
+	firstElems := []string{}
+	for !ans.seekEof() && !ouf.seekEof() {
+		n++
+		j, p := ans.readLong(), ouf.readLong()
+		if j != p {
+			quitf(vWa, "%d%s numbers differ - expected: '%d', found: '%d'", n, englishEnding(n), j, p)
+		} else if n <= 5 {
+			firstElems = append(firstElems, fmt.Sprint(j))
+		}
+	}
+	if extra := countTokens(ans); extra > 0 {
+		quitf(vWa, "Answer contains longer sequence [length = %d], but output contains %d elements", n+extra, n)
+	}
+	if extra := countTokens(ouf); extra > 0 {
+		quitf(vWa, "Output contains longer sequence [length = %d], but answer contains %d elements", n+extra, n)
+	}
+	if n <= 5 {
+		quitf(vOk, "%d number(s): \"%s\"", n, compress(strings.Join(firstElems, " ")))
+	}
+	quitf(vOk, "%d numbers", n)
+}
+
+func doubleCompare(expected, result, maxError float64) bool {
+	maxError += 1e-15
+	switch {
+	case math.IsNaN(expected):
+		return math.IsNaN(result)
+	case math.IsInf(expected, 0):
+		return math.IsInf(result, 0) && (expected > 0) == (result > 0)
+	case math.IsNaN(result) || math.IsInf(result, 0):
+		return false
+	case math.Abs(result-expected) <= maxError:
+		return true
+	}
+	minv := math.Min(expected*(1-maxError), expected*(1+maxError))
+	maxv := math.Max(expected*(1-maxError), expected*(1+maxError))
+	return result >= minv && result <= maxv
+}
+
+func doubleDelta(expected, result float64) float64 {
+	absolute := math.Abs(result - expected)
+	if math.Abs(expected) > 1e-9 {
+		return math.Min(absolute, math.Abs(absolute/expected))
+	}
+	return absolute
+}
+
+// compare two sequences of doubles with max absolute or relative error eps
+func checkRcmp(eps float64, prec int) func(inf, ouf, ans *stream) {
+	return func(inf, ouf, ans *stream) {
+		n := 0
+		var j, p float64
+		for !ans.seekEof() {
+			n++
+			j, p = ans.readDouble(), ouf.readDouble()
+			if !doubleCompare(j, p, eps) {
+				quitf(vWa, "%d%s numbers differ - expected: '%.*f', found: '%.*f', error = '%.*f'",
+					n, englishEnding(n), prec, j, prec, p, prec, doubleDelta(j, p))
+			}
+		}
+		if n == 1 {
+			quitf(vOk, "found '%.*f', expected '%.*f', error '%.*f'", prec, p, prec, j, prec, doubleDelta(j, p))
+		}
+		quitf(vOk, "%d numbers", n)
+	}
+}
+
+// YES or NO (case insensitive)
+func checkYesno(inf, ouf, ans *stream) {
+	ja, pa := strings.ToUpper(ans.readWord()), strings.ToUpper(ouf.readWord())
+	if ja != "YES" && ja != "NO" {
+		quitf(vFail, "YES or NO expected in answer, but %s found", compress(ja))
+	}
+	if pa != "YES" && pa != "NO" {
+		quitf(vPe, "YES or NO expected, but %s found", compress(pa))
+	}
+	if ja != pa {
+		quitf(vWa, "expected %s, found %s", compress(ja), compress(pa))
+	}
+	quitf(vOk, "answer is %s", ja)
+}
+
+// multiple YES/NO (case insensitive)
+func checkNyesno(inf, ouf, ans *stream) {
+	index, yesCount, noCount := 0, 0, 0
+	var pa string
+	for !ans.seekEof() && !ouf.seekEof() {
+		index++
+		ja := strings.ToUpper(ans.readWord())
+		pa = strings.ToUpper(ouf.readWord())
+		if ja != "YES" && ja != "NO" {
+			quitf(vFail, "YES or NO expected in answer, but %s found [%d%s token]", compress(ja), index, englishEnding(index))
+		}
+		switch pa {
+		case "YES":
+			yesCount++
+		case "NO":
+			noCount++
+		default:
+			quitf(vPe, "YES or NO expected, but %s found [%d%s token]", compress(pa), index, englishEnding(index))
+		}
+		if ja != pa {
+			quitf(vWa, "expected %s, found %s [%d%s token]", compress(ja), compress(pa), index, englishEnding(index))
+		}
+	}
+	if extra := countTokens(ans); extra > 0 {
+		quitf(vWa, "Answer contains longer sequence [length = %d], but output contains %d elements", index+extra, index)
+	}
+	if extra := countTokens(ouf); extra > 0 {
+		quitf(vWa, "Output contains longer sequence [length = %d], but answer contains %d elements", index+extra, index)
+	}
+	switch index {
+	case 0:
+		quitf(vOk, "Empty output")
+	case 1:
+		quitf(vOk, "%s", pa)
+	}
+	quitf(vOk, "%d token(s): yes count is %d, no count is %d", index, yesCount, noCount)
+}
+
+// compare unordered sequences of signed int64 numbers
+func checkUncmp(inf, ouf, ans *stream) {
+	var ja, pa []int64
+	for !ans.seekEof() {
+		ja = append(ja, ans.readLong())
+	}
+	for !ouf.seekEof() {
+		pa = append(pa, ouf.readLong())
+	}
+	if len(ja) != len(pa) {
+		quitf(vWa, "Expected %d elements, but %d found", len(ja), len(pa))
+	}
+	sort.Slice(ja, func(i, j int) bool { return ja[i] < ja[j] })
+	sort.Slice(pa, func(i, j int) bool { return pa[i] < pa[j] })
+	for i := range ja {
+		if ja[i] != pa[i] {
+			quitf(vWa, "Expected sequence and output are different (as unordered sequences) [size=%d]", len(ja))
+		}
+	}
+
+	var message string
+	switch len(ja) {
+	case 0:
+		message = "empty sequence"
+	case 1:
+		message = "1 number:"
+	default:
+		message = fmt.Sprintf("%d numbers (in increasing order):", len(ja))
+	}
+	if len(ja) <= 5 {
+		for _, v := range ja {
+			message += fmt.Sprint(" ", v)
+		}
+	} else {
+		message += fmt.Sprintf(" %d %d ... %d %d", ja[0], ja[1], ja[len(ja)-2], ja[len(ja)-1])
+	}
+	quitf(vOk, "%s", message)
+}
+
+// compare two signed int32's
+func checkIcmp(inf, ouf, ans *stream) {
+	ja, pa := ans.readInt(), ouf.readInt()
+	if ja != pa {
+		quitf(vWa, "expected %d, found %d", ja, pa)
+	}
+	quitf(vOk, "answer is %d", ja)
+}
@@ -18,7 +18,12 @@ func (r CheckerTestlib) Label() (inputlabel []string, outputlabel []string) {
 		[]string{"xmlreport", "stderr", "judgerlog"}
 }
 func (r CheckerTestlib) Run(input []string, output []string) *Result {
-	res, err := judger.Judge(
+	return r.RunEnv(processor.Env{}, input, output)
+}
+
+// Paths are resolved in the current directory, env.Dir is not used.
+func (r CheckerTestlib) RunEnv(env processor.Env, input []string, output []string) *Result {
+	res, err := judger.JudgeContext(env.Context,
 		judger.WithArgument("/dev/null", "/dev/null", output[1], input[0],
 			input[1], input[2], input[3], output[0], "-appes"),
 		judger.WithJudger(judger.General),
@@ -36,4 +41,4 @@ func (r CheckerTestlib) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = CheckerTestlib{}
+var _ processor.EnvProcessor = CheckerTestlib{}
@@ -2,35 +2,58 @@ package processors
 
 import (
 	"os"
+	"path"
 	"time"
 
 	"github.com/sshwy/yaoj-core/pkg/private/judger"
 	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/utils"
 )
 
 // Compile source file in all language.
 // For input files, "source" represents source file, "script" represents
-// bash script to compile, where $1 gives source file path and $2 gives output file path
+// bash script to compile, where $1 gives source file path and $2 gives output file path.
+// Files of the repeated input "extra", e.g. other sources and headers, are
+// given to the script after them.
 type Compiler struct {
-	// input: source, script
+	// input: source, script, extra...
 	// output: result, log, judgerlog
 }
 
 func (r Compiler) Label() (inputlabel []string, outputlabel []string) {
-	return []string{"source", "script"}, []string{"result", "log", "judgerlog"}
+	return []string{"source", "script", "extra"}, []string{"result", "log", "judgerlog"}
+}
+
+func (r Compiler) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Required, processor.Repeated}
 }
 
 func (r Compiler) Run(input []string, output []string) *Result {
-	if err := os.Chmod(input[1], 0744); err != nil { // -rwxr--r--
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// The script is copied to and executed in env.Dir.
+func (r Compiler) RunEnv(env processor.Env, input []string, output []string) *Result {
+	script := path.Join(env.Dir, utils.RandomString(10)+".sh")
+	if _, err := utils.CopyFile(input[1], script); err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "copy script: " + err.Error(),
+		}
+	}
+	if err := os.Chmod(script, 0744); err != nil { // -rwxr--r--
 		return &Result{
 			Code: processor.RuntimeError,
 			Msg:  "open script: " + err.Error(),
 		}
 	}
-	defer os.Chmod(input[1], 0644) // -rw-r--r--
-	res, err := judger.Judge(
-		judger.WithArgument("/dev/null", "/dev/null", output[1], input[1], input[0], output[0]),
+	args := append([]string{"/dev/null", "/dev/null", output[1], script, input[0], output[0]}, input[2:]...)
+	res, err := judger.JudgeContext(env.Context,
+		judger.WithArgument(args...),
 		judger.WithJudger(judger.General),
+		judger.WithDir(env.Dir),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(output[2], 0, false),
 		judger.WithRealTime(time.Minute),
@@ -45,4 +68,5 @@ func (r Compiler) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = Compiler{}
+var _ processor.EnvProcessor = Compiler{}
+var _ processor.PortProcessor = Compiler{}
@@ -3,13 +3,12 @@ package processors
 import (
 	"fmt"
 	"path"
-	"time"
 
-	"github.com/sshwy/yaoj-core/pkg/private/judger"
 	"github.com/sshwy/yaoj-core/pkg/processor"
 )
 
-// Compile source file in all language.
+// Compile source file in all language. The language is determined by the
+// suffix of source file according to Langs().
 type CompilerAuto struct {
 	// input: source
 	// output: result, log, judgerlog
@@ -20,42 +19,29 @@ func (r CompilerAuto) Label() (inputlabel []string, outputlabel []string) {
 }
 
 func (r CompilerAuto) Run(input []string, output []string) *Result {
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// Source file is compiled in env.Dir.
+func (r CompilerAuto) RunEnv(env processor.Env, input []string, output []string) *Result {
 	ext := path.Ext(input[0])
-	var arg judger.OptionProvider
-
-	switch ext {
-	case ".c":
-		arg = judger.WithArgument(
-			"/dev/null", "/dev/null", output[1], "/usr/bin/gcc", input[0], "-o", output[0],
-			"-O2", "-lm", "-DONLINE_JUDGE",
-		)
-	case ".cpp", ".cc":
-		arg = judger.WithArgument(
-			"/dev/null", "/dev/null", output[1], "/usr/bin/g++", input[0], "-o", output[0],
-			"-O2", "-lm", "-DONLINE_JUDGE",
-		)
-	default:
+	lang := Langs().Lookup(ext)
+	if lang == nil {
 		return &Result{
 			Code: processor.SystemError,
 			Msg:  fmt.Sprintf("unknown source suffix %s", ext),
 		}
 	}
+	return lang.compile(env, input[0], output)
+}
 
-	res, err := judger.Judge(
-		arg,
-		judger.WithJudger(judger.General),
-		judger.WithPolicy("builtin:free"),
-		judger.WithLog(output[2], 0, false),
-		judger.WithRealTime(time.Minute),
-		judger.WithOutput(10*judger.MB),
-	)
-	if err != nil {
-		return &Result{
-			Code: processor.SystemError,
-			Msg:  err.Error(),
-		}
-	}
-	return res.ProcResult()
+// Fingerprint of Langs(), so that outputs are not reused after the language
+// table changes.
+func (r CompilerAuto) Version() string {
+	return Langs().fingerprint()
 }
 
-var _ Processor = CompilerAuto{}
+var _ processor.EnvProcessor = CompilerAuto{}
+var _ processor.VersionedProcessor = CompilerAuto{}
@@ -0,0 +1,48 @@
+package processors
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+)
+
+// Same as CompilerAuto, except that the language table is given by "config",
+// which is usually provided by static data of a problem. See ParseLangTable
+// for its format.
+type CompilerConfig struct {
+	// input: source, config
+	// output: result, log, judgerlog
+}
+
+func (r CompilerConfig) Label() (inputlabel []string, outputlabel []string) {
+	return []string{"source", "config"}, []string{"result", "log", "judgerlog"}
+}
+
+func (r CompilerConfig) Run(input []string, output []string) *Result {
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// Source file is compiled in env.Dir.
+func (r CompilerConfig) RunEnv(env processor.Env, input []string, output []string) *Result {
+	table, err := LoadLangTable(input[1])
+	if err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "load config: " + err.Error(),
+		}
+	}
+	ext := path.Ext(input[0])
+	lang := table.Lookup(ext)
+	if lang == nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  fmt.Sprintf("unknown source suffix %s", ext),
+		}
+	}
+	return lang.compile(env, input[0], output)
+}
+
+var _ processor.EnvProcessor = CompilerConfig{}
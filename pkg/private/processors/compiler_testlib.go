@@ -2,6 +2,7 @@ package processors
 
 import (
 	"os"
+	"path"
 	"time"
 
 	_ "embed"
@@ -26,7 +27,14 @@ func (r CompilerTestlib) Label() (inputlabel []string, outputlabel []string) {
 }
 
 func (r CompilerTestlib) Run(input []string, output []string) *Result {
-	file, err := os.Create("testlib.h")
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// Source file is copied to env.Dir along with testlib.h.
+func (r CompilerTestlib) RunEnv(env processor.Env, input []string, output []string) *Result {
+	file, err := os.Create(path.Join(env.Dir, "testlib.h"))
 	if err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
@@ -42,16 +50,17 @@ func (r CompilerTestlib) Run(input []string, output []string) *Result {
 	}
 	file.Close()
 
-	src := utils.RandomString(10) + ".cpp"
+	src := path.Join(env.Dir, utils.RandomString(10)+".cpp")
 	if _, err := utils.CopyFile(input[0], src); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
 			Msg:  "copy: " + err.Error(),
 		}
 	}
-	res, err := judger.Judge(
+	res, err := judger.JudgeContext(env.Context,
 		judger.WithArgument("/dev/null", "/dev/null", output[1], "/usr/bin/g++", src, "-o", output[0], "-O2", "-Wall"),
 		judger.WithJudger(judger.General),
+		judger.WithDir(env.Dir),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(output[2], 0, false),
 		judger.WithRealTime(time.Minute),
@@ -66,4 +75,4 @@ func (r CompilerTestlib) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = CompilerTestlib{}
+var _ processor.EnvProcessor = CompilerTestlib{}
@@ -10,7 +10,8 @@ import (
 )
 
 // Execute testlib generator.
-// Arguments in "arguments" are seperated by space.
+// Arguments in "arguments" are seperated by space, which is overridden by
+// param "arguments" of the node, so "arguments" is optional in that case.
 type GeneratorTestlib struct {
 	// input: generator arguments
 	// output: output stderr judgerlog
@@ -19,22 +20,55 @@ type GeneratorTestlib struct {
 func (r GeneratorTestlib) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"generator", "arguments"}, []string{"output", "stderr", "judgerlog"}
 }
+func (r GeneratorTestlib) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Optional}
+}
+
 func (r GeneratorTestlib) Run(input []string, output []string) *Result {
-	args, err := os.ReadFile(input[1])
-	if err != nil {
+	return r.RunEnv(processor.Env{}, input, output)
+}
+
+// Paths are resolved in the current directory, env.Dir is not used.
+func (r GeneratorTestlib) RunEnv(env processor.Env, input []string, output []string) *Result {
+	return r.RunParams(env, nil, input, output)
+}
+
+func (r GeneratorTestlib) ParamKeys() []string {
+	return []string{"arguments"}
+}
+
+func (r GeneratorTestlib) InputParams() map[string][][]string {
+	return map[string][][]string{
+		"arguments": {{"arguments"}},
+	}
+}
+
+func (r GeneratorTestlib) RunParams(env processor.Env, params processor.Params, input []string, output []string) *Result {
+	if err := checkPortParams(r, params, input); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "open arguments: " + err.Error(),
+			Msg:  err.Error(),
+		}
+	}
+	args, ok := params["arguments"]
+	if !ok {
+		content, err := os.ReadFile(input[1])
+		if err != nil {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "open arguments: " + err.Error(),
+			}
 		}
+		args = string(content)
 	}
-	argv := strings.Split(string(args), " ")
+	argv := strings.Split(args, " ")
 	finalArgv := []string{"/dev/null", output[0], output[1], input[0]}
 	for _, v := range argv {
 		if v != "" {
 			finalArgv = append(finalArgv, v)
 		}
 	}
-	res, err := judger.Judge(
+	res, err := judger.JudgeContext(env.Context,
 		judger.WithArgument(finalArgv...),
 		judger.WithJudger(judger.General),
 		judger.WithPolicy("builtin:free"),
@@ -51,4 +85,6 @@ func (r GeneratorTestlib) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = GeneratorTestlib{}
+var _ processor.EnvProcessor = GeneratorTestlib{}
+var _ processor.ParamProcessor = GeneratorTestlib{}
+var _ processor.ParamPortProcessor = GeneratorTestlib{}
@@ -2,9 +2,11 @@ package processors
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/sshwy/yaoj-core/pkg/private/judger"
+	"github.com/sshwy/yaoj-core/pkg/processor"
 )
 
 // `s` contains a series of number seperated by space, denoting
@@ -39,3 +41,95 @@ func parseJudgerLimit(s string) ([]judger.OptionProvider, error) {
 	}
 	return options, nil
 }
+
+// Read limitations in file name, which is "" if the input is unconnected and
+// limitations are given by params only.
+func readJudgerLimit(name string) ([]judger.OptionProvider, error) {
+	if name == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("open limit: %v", err)
+	}
+	options, err := parseJudgerLimit(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parse judger limit: %v", err)
+	}
+	return options, nil
+}
+
+// Params of limitations, which override those given in "limit" file.
+var limitParams = []string{"realtime", "cputime", "virmem", "realmem", "stackmem", "outputlimit", "fileno"}
+
+// Parse limitations in params: "realtime", "cputime" (ms), "virmem",
+// "realmem", "stackmem", "outputlimit" (byte) and "fileno".
+func parseLimitParams(params processor.Params) ([]judger.OptionProvider, error) {
+	options := []judger.OptionProvider{}
+	for _, key := range limitParams {
+		v, ok, err := params.Int(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		switch key {
+		case "realtime":
+			options = append(options, judger.WithRealTime(time.Millisecond*time.Duration(v)))
+		case "cputime":
+			options = append(options, judger.WithCpuTime(time.Millisecond*time.Duration(v)))
+		case "virmem":
+			options = append(options, judger.WithVirMemory(judger.ByteValue(v)))
+		case "realmem":
+			options = append(options, judger.WithRealMemory(judger.ByteValue(v)))
+		case "stackmem":
+			options = append(options, judger.WithStack(judger.ByteValue(v)))
+		case "outputlimit":
+			options = append(options, judger.WithOutput(judger.ByteValue(v)))
+		case "fileno":
+			options = append(options, judger.WithFileno(v))
+		}
+	}
+	return options, nil
+}
+
+// Check that keys of params are all known.
+func checkParams(params processor.Params, known ...[]string) error {
+	for _, key := range params.Keys() {
+		found := false
+		for _, list := range known {
+			for _, k := range list {
+				if k == key {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown param %q", key)
+		}
+	}
+	return nil
+}
+
+// Check that params are known to proc, and given instead of unconnected
+// inputs.
+func checkPortParams(proc processor.ParamPortProcessor, params processor.Params, input []string) error {
+	if err := checkParams(params, proc.ParamKeys()); err != nil {
+		return err
+	}
+	return processor.CheckInputs(proc, input, params)
+}
+
+// Run f in a temporary directory, which is removed afterwards.
+func runInTempDir(f func(env processor.Env) *Result) *Result {
+	dir, err := os.MkdirTemp("", "yaoj-processor-*")
+	if err != nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  "create temp dir: " + err.Error(),
+		}
+	}
+	defer os.RemoveAll(dir)
+	return f(processor.Env{Dir: dir})
+}
@@ -10,7 +10,9 @@ import (
 
 // Inputmaker make input according to "option": "raw" means "source" provides
 // input content, "generator" means execute "generator" with arguments in
-// "source", separated by space.
+// "source", separated by space. Param "option" of the node overrides the file,
+// so input "option" is optional, and so is "generator", which is only required
+// by the latter.
 type Inputmaker struct {
 	// source option generator
 	// output: result stderr judgerlog
@@ -20,15 +22,43 @@ func (r Inputmaker) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"source", "option", "generator"}, []string{"result", "stderr", "judgerlog"}
 }
 
+func (r Inputmaker) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Optional, processor.Optional}
+}
+
 func (r Inputmaker) Run(input []string, output []string) *Result {
-	option, err := os.ReadFile(input[1])
-	if err != nil {
+	return r.RunParams(processor.Env{}, nil, input, output)
+}
+
+func (r Inputmaker) ParamKeys() []string {
+	return []string{"option"}
+}
+
+func (r Inputmaker) InputParams() map[string][][]string {
+	return map[string][][]string{
+		"option": {{"option"}},
+	}
+}
+
+func (r Inputmaker) RunParams(env processor.Env, params processor.Params, input []string, output []string) *Result {
+	if err := checkPortParams(r, params, input); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "open option: " + err.Error(),
+			Msg:  err.Error(),
 		}
 	}
-	if strings.Contains(string(option), "raw") {
+	option, ok := params["option"]
+	if !ok {
+		content, err := os.ReadFile(input[1])
+		if err != nil {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "open option: " + err.Error(),
+			}
+		}
+		option = string(content)
+	}
+	if strings.Contains(option, "raw") {
 		if _, err := utils.CopyFile(input[0], output[0]); err != nil {
 			return &Result{
 				Code: processor.RuntimeError,
@@ -37,9 +67,17 @@ func (r Inputmaker) Run(input []string, output []string) *Result {
 		}
 		return &Result{Code: processor.Ok}
 	} else { // testlib
+		if input[2] == "" {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "generator missing",
+			}
+		}
 		runner := GeneratorTestlib{}
-		return runner.Run([]string{input[2], input[0]}, output)
+		return runner.RunEnv(env, []string{input[2], input[0]}, output)
 	}
 }
 
-var _ Processor = Inputmaker{}
+var _ processor.ParamProcessor = Inputmaker{}
+var _ processor.PortProcessor = Inputmaker{}
+var _ processor.ParamPortProcessor = Inputmaker{}
@@ -0,0 +1,251 @@
+package processors
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/private/judger"
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/utils"
+)
+
+//go:embed langs.json
+var defaultLangs []byte
+
+// Language describes how to turn a source file into an executable.
+//
+// In Compile and Wrapper, "{source}", "{artefact}" and "{dir}" are replaced
+// by the path of source file, artefact and working directory respectively.
+//
+// Run is the argv to run the artefact, whose last argument must be
+// "{artefact}", e.g. ["python3", "{artefact}"]. The program is looked up in
+// PATH when running, through a "#!/usr/bin/env -S" line prepended to the
+// artefact, thus arguments must not contain spaces, quotes, backslashes or
+// "$". Empty Run means the artefact is executable itself, unless Wrapper is
+// given instead.
+type Language struct {
+	// name of the LangTag, e.g. "cpp17"
+	Lang string `json:"lang"`
+	// file extensions, e.g. [".cpp", ".cc"]
+	Ext []string `json:"ext"`
+	// file name of source file in working directory
+	Source string `json:"source"`
+	// argv to compile the source, executed in working directory.
+	// Empty for interpreted languages.
+	Compile []string `json:"compile,omitempty"`
+	// file name of the compiled file in working directory, default Source
+	Artefact string `json:"artefact,omitempty"`
+	// argv to run the artefact
+	Run []string `json:"run,omitempty"`
+	// prepended to the artefact to make it runnable, e.g. a shebang line.
+	// Generated from Run if empty.
+	Wrapper string `json:"wrapper,omitempty"`
+	// sandbox policy to compile, default "builtin:free"
+	Policy string `json:"policy,omitempty"`
+}
+
+func (r Language) Tag() utils.LangTag {
+	tag, _ := utils.ParseLangTag(r.Lang)
+	return tag
+}
+
+func (r Language) expand(s string, dir string) string {
+	artefact := r.Artefact
+	if artefact == "" {
+		artefact = r.Source
+	}
+	return strings.NewReplacer(
+		"{source}", path.Join(dir, r.Source),
+		"{artefact}", path.Join(dir, artefact),
+		"{dir}", dir,
+	).Replace(s)
+}
+
+// Compile source in env.Dir. The artefact, prefixed with wrapper, is written
+// to output[0] as an executable.
+func (r Language) compile(env processor.Env, source string, output []string) *Result {
+	if _, err := utils.CopyFile(source, path.Join(env.Dir, r.Source)); err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "copy: " + err.Error(),
+		}
+	}
+	result := &Result{Code: processor.Ok}
+	if len(r.Compile) > 0 {
+		argv := []string{"/dev/null", "/dev/null", output[1]}
+		for _, arg := range r.Compile {
+			argv = append(argv, r.expand(arg, env.Dir))
+		}
+		policy := r.Policy
+		if policy == "" {
+			policy = "builtin:free"
+		}
+		res, err := judger.JudgeContext(env.Context,
+			judger.WithArgument(argv...),
+			judger.WithJudger(judger.General),
+			judger.WithDir(env.Dir),
+			judger.WithPolicy(policy),
+			judger.WithLog(output[2], 0, false),
+			judger.WithRealTime(time.Minute),
+			judger.WithOutput(10*judger.MB),
+		)
+		if err != nil {
+			return &Result{
+				Code: processor.SystemError,
+				Msg:  err.Error(),
+			}
+		}
+		result = res.ProcResult()
+		if result.Code != processor.Ok {
+			return result
+		}
+	} else {
+		os.WriteFile(output[1], nil, 0644)
+		os.WriteFile(output[2], nil, 0644)
+	}
+
+	if err := r.writeArtefact(env.Dir, output[0]); err != nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  "write artefact: " + err.Error(),
+		}
+	}
+	return result
+}
+
+// shebang line running the artefact by Run
+func (r Language) runWrapper() string {
+	if len(r.Run) == 0 {
+		return ""
+	}
+	return "#!/usr/bin/env -S " + strings.Join(r.Run[:len(r.Run)-1], " ") + "\n"
+}
+
+func (r Language) checkRun() error {
+	if len(r.Run) == 0 {
+		return nil
+	}
+	if r.Wrapper != "" {
+		return fmt.Errorf("run and wrapper are exclusive")
+	}
+	if len(r.Run) < 2 || r.Run[len(r.Run)-1] != "{artefact}" {
+		return fmt.Errorf("the last argument of run should be {artefact}")
+	}
+	for _, arg := range r.Run[:len(r.Run)-1] {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$") || strings.Contains(arg, "{artefact}") {
+			return fmt.Errorf("invalid run argument %q", arg)
+		}
+	}
+	return nil
+}
+
+func (r Language) writeArtefact(dir string, name string) error {
+	src, err := os.Open(r.expand("{artefact}", dir))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	wrapper := r.expand(r.Wrapper, dir)
+	if wrapper == "" {
+		wrapper = r.runWrapper()
+	}
+	if _, err := dest.WriteString(wrapper); err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		return err
+	}
+	return os.Chmod(name, 0755)
+}
+
+// Languages identified by file extension.
+type LangTable []Language
+
+// Parse a LangTable from JSON, see langs.json for example.
+func ParseLangTable(data []byte) (LangTable, error) {
+	var table LangTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	for i, lang := range table {
+		if _, err := utils.ParseLangTag(lang.Lang); err != nil {
+			return nil, fmt.Errorf("language #%d: %w", i, err)
+		}
+		if len(lang.Ext) == 0 || lang.Source == "" {
+			return nil, fmt.Errorf("language #%d (%s): ext and source are required", i, lang.Lang)
+		}
+		if err := lang.checkRun(); err != nil {
+			return nil, fmt.Errorf("language #%d (%s): %w", i, lang.Lang, err)
+		}
+	}
+	return table, nil
+}
+
+func LoadLangTable(name string) (LangTable, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLangTable(data)
+}
+
+// Find language by file extension (with dot), nil if not found.
+func (r LangTable) Lookup(ext string) *Language {
+	for i := range r {
+		for _, e := range r[i].Ext {
+			if e == ext {
+				return &r[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Find language by tag, nil if not found.
+func (r LangTable) Get(tag utils.LangTag) *Language {
+	for i := range r {
+		if r[i].Tag() == tag {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// SHA256 of the JSON form of the table.
+func (r LangTable) fingerprint() string {
+	data, _ := json.Marshal(r)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+var langTable atomic.Value
+
+// Language table used by compiler:auto.
+func Langs() LangTable {
+	return langTable.Load().(LangTable)
+}
+
+// Set the language table used by compiler:auto.
+func SetLangs(table LangTable) {
+	langTable.Store(table)
+}
+
+func init() {
+	table, err := ParseLangTable(defaultLangs)
+	if err != nil {
+		panic(err)
+	}
+	SetLangs(table)
+}
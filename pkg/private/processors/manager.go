@@ -1,5 +1,7 @@
 package processors
 
+import "github.com/sshwy/yaoj-core/pkg/processor"
+
 var processors map[string]Processor = make(map[string]Processor)
 
 func Get(name string) Processor {
@@ -13,6 +15,7 @@ func GetAll() map[string]Processor {
 // register a processor to system
 func Register(name string, proc Processor) {
 	processors[name] = proc
+	processor.Register(name, proc)
 }
 
 func init() {
@@ -23,6 +26,11 @@ func init() {
 	Register("generator:testlib", GeneratorTestlib{})
 	Register("runner:fileio", RunnerFileio{})
 	Register("runner:stdio", RunnerStdio{})
+	Register("runner:interactive", RunnerInteractive{})
 	Register("compiler:testlib", CompilerTestlib{})
 	Register("compiler:auto", CompilerAuto{})
+	Register("compiler:config", CompilerConfig{})
+	for name := range stdCheckers {
+		Register("checker:"+name, CheckerStd{Name: name})
+	}
 }
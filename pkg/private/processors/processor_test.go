@@ -5,21 +5,60 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/bitfield/script"
 	"github.com/sshwy/yaoj-core/pkg/private/processors"
 	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/workflow"
 )
 
 //go:generate go build -buildmode=plugin -o ./testdata/diff-go ./testdata/diff-go/main.go
 func TestLoad(t *testing.T) {
 	proc, err := processor.LoadPlugin("testdata/diff-go/main.so")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(proc.Label())
+
+	// registered processors are known to workflow graphs
+	processors.Register("plugin:diff", proc)
+	if !processor.Exists("plugin:diff") {
+		t.Fatal("plugin:diff not exists")
+	}
+	var b workflow.Builder
+	b.SetNode("diff", "plugin:diff", true)
+	b.AddInbound(workflow.Gsubm, "source", "diff", "filea")
+	b.AddInbound(workflow.Gtests, "answer", "diff", "fileb")
+	if _, err := b.WorkflowGraph(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJudgerLimit(t *testing.T) {
+	dir := t.TempDir()
+	lim := path.Join(dir, "lim")
+	script.Echo("1000 1000").WriteFile(lim)
+	output := []string{path.Join(dir, "out"), path.Join(dir, "err"), path.Join(dir, "log")}
+	runner := processors.RunnerStdio{}
+	// rejected before running the program
+	for _, c := range []struct {
+		limit  string
+		params processor.Params
+	}{
+		{lim, nil},
+		{"", nil},
+		{"", processor.Params{"fileno": "10"}},
+	} {
+		res := processor.RunWithParams(runner, processor.Env{Dir: dir}, c.params,
+			[]string{"/bin/true", "/dev/null", c.limit}, output)
+		t.Log(res)
+		if res.Code != processor.RuntimeError {
+			t.Errorf("limit %q params %v: expect RuntimeError, found %v", c.limit, c.params, res.Code)
+		}
+	}
 }
 
 func TestProcessor(t *testing.T) {
@@ -57,6 +96,74 @@ func TestProcessor(t *testing.T) {
 		t.Log(res)
 	})
 
+	t.Run("CompilerAuto", func(t *testing.T) {
+		script.Echo("print(int(input()) * 2)\n").WriteFile(path.Join(dir, "double.py"))
+		compiler := processors.CompilerAuto{}
+		for _, source := range []string{"testdata/main.cpp", path.Join(dir, "double.py")} {
+			res := compiler.Run(
+				[]string{source},
+				[]string{path.Join(dir, "auto"), path.Join(dir, "auto.log"), path.Join(dir, "auto.jlog")},
+			)
+			if res.Code != processor.Ok {
+				t.Errorf("expect %v, found %v Msg=%s", processor.Ok, res.Code, res.Msg)
+				return
+			}
+			output, err := script.Echo("21").Exec(path.Join(dir, "auto")).String()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			t.Log(source, "output:", output)
+		}
+		res := compiler.Run(
+			[]string{"testdata/script.sh"},
+			[]string{path.Join(dir, "auto"), path.Join(dir, "auto.log"), path.Join(dir, "auto.jlog")},
+		)
+		if res.Code != processor.SystemError {
+			t.Errorf("expect %v, found %v", processor.SystemError, res.Code)
+		}
+	})
+
+	t.Run("CompilerConfig", func(t *testing.T) {
+		script.Echo(`[{"lang": "python3", "ext": [".py"], "source": "a.py", "wrapper": "#!/usr/bin/python3\n"}]`).
+			WriteFile(path.Join(dir, "langs.json"))
+		compiler := processors.CompilerConfig{}
+		res := compiler.Run(
+			[]string{path.Join(dir, "double.py"), path.Join(dir, "langs.json")},
+			[]string{path.Join(dir, "config"), path.Join(dir, "config.log"), path.Join(dir, "config.jlog")},
+		)
+		if res.Code != processor.Ok {
+			t.Errorf("expect %v, found %v Msg=%s", processor.Ok, res.Code, res.Msg)
+			return
+		}
+		output, _ := script.Echo("21").Exec(path.Join(dir, "config")).String()
+		if output != "42\n" {
+			t.Errorf("unexpected output %q", output)
+		}
+
+		// run template looked up in PATH
+		script.Echo(`[{"lang": "python3", "ext": [".py"], "source": "a.py", "run": ["python3", "{artefact}"]}]`).
+			WriteFile(path.Join(dir, "langs.json"))
+		res = compiler.Run(
+			[]string{path.Join(dir, "double.py"), path.Join(dir, "langs.json")},
+			[]string{path.Join(dir, "config"), path.Join(dir, "config.log"), path.Join(dir, "config.jlog")},
+		)
+		if res.Code != processor.Ok {
+			t.Errorf("expect %v, found %v Msg=%s", processor.Ok, res.Code, res.Msg)
+			return
+		}
+		output, _ = script.Echo("21").Exec(path.Join(dir, "config")).String()
+		if output != "42\n" {
+			t.Errorf("unexpected output %q", output)
+		}
+		for _, run := range []string{`["python3"]`, `["python3", "{artefact}", "-u"]`, `["python3 -u", "{artefact}"]`} {
+			_, err := processors.ParseLangTable([]byte(`[{"lang": "python3", "ext": [".py"], "source": "a.py", "run": ` + run + `}]`))
+			if err == nil {
+				t.Errorf("expect error for run %s", run)
+			}
+		}
+	})
+
 	t.Run("RunnerStdio", func(t *testing.T) {
 		fa := path.Join(dir, "a.rsi.in")
 		fb := path.Join(dir, "lim.rsi.in")
@@ -75,6 +182,16 @@ func TestProcessor(t *testing.T) {
 
 		output, _ := script.File(path.Join(dir, "dest.out")).String()
 		t.Log("output:", output)
+
+		// limitations are given by params without "limit"
+		res = processor.RunWithParams(runner, processor.Env{Dir: t.TempDir()}, processor.Params{"realtime": "1000", "cputime": "1000"},
+			[]string{path.Join(dir, "dest"), fa, ""},
+			[]string{path.Join(dir, "dest2.out"), path.Join(dir, "dest2.err"), path.Join(dir, "dest2.judger.log")},
+		)
+		t.Log(res)
+		if res.Code != processor.Ok {
+			t.Errorf("invalid result")
+		}
 	})
 
 	t.Run("RunnerFileio", func(t *testing.T) {
@@ -129,6 +246,68 @@ func TestProcessor(t *testing.T) {
 		t.Log(script.File(path.Join(dir, "rep")).String())
 	})
 
+	t.Run("RunnerInteractive", func(t *testing.T) {
+		script.Echo("#!/bin/sh\nread x\necho $((x*2))\n").WriteFile(path.Join(dir, "itct.exec"))
+		script.Echo("#!/bin/sh\nread n < \"$1\"\necho $n\nread x\necho $x > \"$2\"\n").WriteFile(path.Join(dir, "itct"))
+		os.Chmod(path.Join(dir, "itct.exec"), 0744)
+		os.Chmod(path.Join(dir, "itct"), 0744)
+		script.Echo("5").WriteFile(path.Join(dir, "itct.in"))
+		script.Echo("1000 1000 204857600 204857600 204857600 204857600 0").WriteFile(path.Join(dir, "lim.itct"))
+		runner := processors.RunnerInteractive{}
+		res := runner.Run(
+			[]string{path.Join(dir, "itct.exec"), path.Join(dir, "itct"), path.Join(dir, "itct.in"), path.Join(dir, "lim.itct")},
+			[]string{path.Join(dir, "itct.out"), path.Join(dir, "itct.err"), path.Join(dir, "itct.jlog")},
+		)
+		t.Log(res)
+		if res.Code != processor.Ok {
+			t.Errorf("invalid result")
+			return
+		}
+
+		output, _ := script.File(path.Join(dir, "itct.out")).String()
+		t.Log("output:", output)
+	})
+
+	t.Run("CheckerStd", func(t *testing.T) {
+		for i, c := range []struct {
+			name, output, answer, outcome string
+		}{
+			{"wcmp", "1  2\n3", "1 2 3", "accepted"},
+			{"wcmp", "1 3", "1 2", "wrong-answer"},
+			{"lcmp", "a  b\nc\n", "a b\nc", "accepted"},
+			{"lcmp", "a b\nc", "a b c", "wrong-answer"},
+			{"lcmp", "a", "a\nb", "presentation-error"},
+			{"fcmp", "a  b", "a b", "wrong-answer"},
+			{"ncmp", "-5\n", "-5", "accepted"},
+			{"ncmp", "1 2 3 4", "1 2 3", "wrong-answer"},
+			{"ncmp", "x", "1", "presentation-error"},
+			{"rcmp6", "1", "1.0000001", "accepted"},
+			{"rcmp6", "1", "1.1", "wrong-answer"},
+			{"yesno", "YES", "Yes", "accepted"},
+			{"yesno", "maybe", "yes", "presentation-error"},
+			{"yesno", "yes", "maybe", "fail"},
+			{"nyesno", "yes no", "YES NO", "accepted"},
+			{"uncmp", "1 2 3", "3 1 2", "accepted"},
+			{"icmp", "42 43", "42", "presentation-error"},
+		} {
+			script.Echo(c.output).WriteFile(path.Join(dir, "std.out"))
+			script.Echo(c.answer).WriteFile(path.Join(dir, "std.ans"))
+			checker := processors.CheckerStd{Name: c.name}
+			res := checker.Run(
+				[]string{"/dev/null", path.Join(dir, "std.out"), path.Join(dir, "std.ans")},
+				[]string{path.Join(dir, "std.xml"), path.Join(dir, "std.err")},
+			)
+			report, _ := script.File(path.Join(dir, "std.xml")).String()
+			t.Log(i, res, report)
+			if (res.Code == processor.Ok) != (c.outcome == "accepted") {
+				t.Errorf("#%d: unexpected code %v", i, res.Code)
+			}
+			if !strings.Contains(report, fmt.Sprintf(`outcome = "%s"`, c.outcome)) {
+				t.Errorf("#%d: expect outcome %s", i, c.outcome)
+			}
+		}
+	})
+
 	t.Run("GeneratorTestlib", func(t *testing.T) {
 		script.Exec(fmt.Sprintf("clang++ testdata/igen.cpp -o %s", path.Join(dir, "igen"))).Wait()
 		script.Echo("1 4 2 8 5    7").WriteFile(path.Join(dir, "igenparam"))
@@ -169,6 +348,54 @@ func TestProcessor(t *testing.T) {
 			return
 		}
 		t.Log(script.File(path.Join(dir, "igen3.out")).String())
+
+		// param overrides option file
+		res = processor.RunWithParams(runner, processor.Env{}, processor.Params{"option": "raw"},
+			[]string{path.Join(dir, "igenparam"), path.Join(dir, "genopt"), "/dev/null"},
+			[]string{path.Join(dir, "igen4.out"), path.Join(dir, "igen4.err"), path.Join(dir, "igen4.log")},
+		)
+		if res.Code != processor.Ok {
+			t.Errorf("invalid result %v", res)
+			return
+		}
+		res = processor.RunWithParams(runner, processor.Env{}, processor.Params{"option": "raw"},
+			[]string{path.Join(dir, "igenparam"), "", ""},
+			[]string{path.Join(dir, "igen6.out"), path.Join(dir, "igen6.err"), path.Join(dir, "igen6.log")},
+		)
+		if res.Code != processor.Ok {
+			t.Errorf("invalid result %v", res)
+			return
+		}
+		res = runner.Run(
+			[]string{path.Join(dir, "igenparam"), "", ""},
+			[]string{path.Join(dir, "igen7.out"), path.Join(dir, "igen7.err"), path.Join(dir, "igen7.log")},
+		)
+		if res.Code == processor.Ok {
+			t.Errorf("expect error for missing option")
+		}
+		res = processor.RunWithParams(runner, processor.Env{}, processor.Params{"unknown": "1"},
+			[]string{path.Join(dir, "igenparam"), path.Join(dir, "rawopt"), "/dev/null"},
+			[]string{path.Join(dir, "igen5.out"), path.Join(dir, "igen5.err"), path.Join(dir, "igen5.log")},
+		)
+		if res.Code == processor.Ok {
+			t.Errorf("expect error for unknown param")
+		}
+
+		// generator is optional in raw mode only
+		res = runner.Run(
+			[]string{path.Join(dir, "igenparam"), path.Join(dir, "rawopt"), ""},
+			[]string{path.Join(dir, "igen6.out"), path.Join(dir, "igen6.err"), path.Join(dir, "igen6.log")},
+		)
+		if res.Code != processor.Ok {
+			t.Errorf("invalid result %v", res)
+		}
+		res = runner.Run(
+			[]string{path.Join(dir, "igenparam"), path.Join(dir, "genopt"), ""},
+			[]string{path.Join(dir, "igen7.out"), path.Join(dir, "igen7.err"), path.Join(dir, "igen7.log")},
+		)
+		if res.Code == processor.Ok {
+			t.Errorf("expect error for missing generator")
+		}
 	})
 
 	t.Run("CompilerTestlib", func(t *testing.T) {
@@ -3,6 +3,7 @@ package processors
 import (
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/sshwy/yaoj-core/pkg/private/judger"
@@ -13,7 +14,9 @@ import (
 // Run a program reading from file and print to file and stderr.
 // File "config" contains two lines, the first of which acts the same as
 // "limit" of RunnerStdio while the second contains two strings denoting input
-// file and output file.
+// file and output file. Limitations are overridden by params as RunnerStdio,
+// and file names by params "inputfile" and "outputfile". "config" is optional
+// if both file names, and "realtime" or "cputime" are given by params.
 type RunnerFileio struct {
 	// input: executable, fin, config
 	// output: fout, stderr, judgerlog
@@ -23,24 +26,77 @@ func (r RunnerFileio) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"executable", "fin", "config"}, []string{"fout", "stderr", "judgerlog"}
 }
 
+func (r RunnerFileio) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Required, processor.Optional}
+}
+
 func (r RunnerFileio) Run(input []string, output []string) *Result {
-	lim, err := os.ReadFile(input[2])
-	if err != nil {
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// The program is executed in env.Dir, where its input and output files lie.
+func (r RunnerFileio) RunEnv(env processor.Env, input []string, output []string) *Result {
+	return r.RunParams(env, nil, input, output)
+}
+
+func (r RunnerFileio) ParamKeys() []string {
+	return append(append([]string{}, limitParams...), "inputfile", "outputfile")
+}
+
+func (r RunnerFileio) InputParams() map[string][][]string {
+	return map[string][][]string{
+		"config": {{"inputfile"}, {"outputfile"}, {"realtime", "cputime"}},
+	}
+}
+
+func (r RunnerFileio) RunParams(env processor.Env, params processor.Params, input []string, output []string) *Result {
+	if err := checkPortParams(r, params, input); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "open config: " + err.Error(),
+			Msg:  err.Error(),
 		}
 	}
-	lines := strings.Split(string(lim), "\n")
-	if len(lines) != 2 {
-		return &Result{
-			Code: processor.RuntimeError,
-			Msg:  "invalid config",
+	lines, limit := []string{"", ""}, []judger.OptionProvider{}
+	if input[2] != "" {
+		content, err := os.ReadFile(input[2])
+		if err != nil {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "open config: " + err.Error(),
+			}
+		}
+		lines = strings.Split(string(content), "\n")
+		if len(lines) != 2 {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "invalid config",
+			}
+		}
+		if limit, err = parseJudgerLimit(lines[0]); err != nil {
+			return &Result{
+				Code: processor.RuntimeError,
+				Msg:  "parse judger limit: " + err.Error(),
+			}
 		}
 	}
 	var inf, ouf string
 	fmt.Sscanf(lines[1], "%s%s", &inf, &ouf)
+	if name, ok := params["inputfile"]; ok {
+		inf = name
+	}
+	if name, ok := params["outputfile"]; ok {
+		ouf = name
+	}
+	if inf == "" || ouf == "" {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "input or output file missing",
+		}
+	}
 	logger.Printf("inf=%q, out=%q", inf, ouf)
+	inf, ouf = path.Join(env.Dir, inf), path.Join(env.Dir, ouf)
 	if _, err := utils.CopyFile(input[1], inf); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
@@ -50,18 +106,20 @@ func (r RunnerFileio) Run(input []string, output []string) *Result {
 	options := []judger.OptionProvider{
 		judger.WithArgument("/dev/null", "/dev/null", output[1], input[0]),
 		judger.WithJudger(judger.General),
+		judger.WithDir(env.Dir),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(output[2], 0, false),
 	}
-	more, err := parseJudgerLimit(lines[0])
+	options = append(options, limit...)
+	more, err := parseLimitParams(params)
 	if err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "parse judger limit: " + err.Error(),
+			Msg:  "parse params: " + err.Error(),
 		}
 	}
 	options = append(options, more...)
-	res, err := judger.Judge(options...)
+	res, err := judger.JudgeContext(env.Context, options...)
 	if err != nil {
 		return &Result{
 			Code: processor.SystemError,
@@ -72,4 +130,12 @@ func (r RunnerFileio) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = RunnerFileio{}
+func (r RunnerFileio) Cacheable() bool {
+	return false
+}
+
+var _ processor.EnvProcessor = RunnerFileio{}
+var _ processor.ParamProcessor = RunnerFileio{}
+var _ processor.CacheableProcessor = RunnerFileio{}
+var _ processor.PortProcessor = RunnerFileio{}
+var _ processor.ParamPortProcessor = RunnerFileio{}
@@ -0,0 +1,95 @@
+package processors
+
+import (
+	"github.com/sshwy/yaoj-core/pkg/private/judger"
+	"github.com/sshwy/yaoj-core/pkg/processor"
+)
+
+// Run a program interacting with an interactor, whose stdin and stdout are
+// piped together. The interactor is executed as `interactor input result`
+// (testlib style), so "result" is the output file of interactor, which is
+// usually checked by a checker later. "stderr" is the stderr of interactor.
+// "limit" and params act the same as RunnerStdio, applied to the program.
+type RunnerInteractive struct {
+	// input: executable, interactor, input, limit
+	// output: result, stderr, judgerlog
+}
+
+func (r RunnerInteractive) Label() (inputlabel []string, outputlabel []string) {
+	return []string{"executable", "interactor", "input", "limit"}, []string{"result", "stderr", "judgerlog"}
+}
+
+func (r RunnerInteractive) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Required, processor.Required, processor.Optional}
+}
+
+func (r RunnerInteractive) Run(input []string, output []string) *Result {
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// Both the program and interactor are executed in env.Dir.
+func (r RunnerInteractive) RunEnv(env processor.Env, input []string, output []string) *Result {
+	return r.RunParams(env, nil, input, output)
+}
+
+func (r RunnerInteractive) ParamKeys() []string {
+	return limitParams
+}
+
+func (r RunnerInteractive) InputParams() map[string][][]string {
+	return map[string][][]string{
+		"limit": {{"realtime", "cputime"}},
+	}
+}
+
+func (r RunnerInteractive) RunParams(env processor.Env, params processor.Params, input []string, output []string) *Result {
+	if err := checkPortParams(r, params, input); err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  err.Error(),
+		}
+	}
+	options := []judger.OptionProvider{
+		judger.WithArgument(input[0], input[1], input[2], output[0], output[1], "/dev/null"),
+		judger.WithJudger(judger.Interactive),
+		judger.WithDir(env.Dir),
+		judger.WithPolicy("builtin:free"),
+		judger.WithLog(output[2], 0, false),
+	}
+	more, err := readJudgerLimit(input[3])
+	if err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  err.Error(),
+		}
+	}
+	options = append(options, more...)
+	more, err = parseLimitParams(params)
+	if err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "parse params: " + err.Error(),
+		}
+	}
+	options = append(options, more...)
+	res, err := judger.JudgeContext(env.Context, options...)
+	if err != nil {
+		return &Result{
+			Code: processor.SystemError,
+			Msg:  err.Error(),
+		}
+	}
+	return res.ProcResult()
+}
+
+func (r RunnerInteractive) Cacheable() bool {
+	return false
+}
+
+var _ processor.EnvProcessor = RunnerInteractive{}
+var _ processor.ParamProcessor = RunnerInteractive{}
+var _ processor.CacheableProcessor = RunnerInteractive{}
+var _ processor.PortProcessor = RunnerInteractive{}
+var _ processor.ParamPortProcessor = RunnerInteractive{}
@@ -1,8 +1,6 @@
 package processors
 
 import (
-	"os"
-
 	"github.com/sshwy/yaoj-core/pkg/private/judger"
 	"github.com/sshwy/yaoj-core/pkg/processor"
 )
@@ -11,6 +9,9 @@ import (
 // For "limit", it contains a series of number seperated by space, denoting
 // real time (ms), cpu time (ms), virtual memory (byte), real memory (byte),
 // stack memory (byte), output limit (byte), fileno limitation respectively.
+// They are overridden by params "realtime", "cputime", "virmem", "realmem",
+// "stackmem", "outputlimit" and "fileno" of the node. "limit" is optional if
+// param "realtime" or "cputime" is given, so that the program is limited.
 type RunnerStdio struct {
 	// input: executable, stdin, limit
 	// output: stdout, stderr, judgerlog
@@ -19,29 +20,62 @@ type RunnerStdio struct {
 func (r RunnerStdio) Label() (inputlabel []string, outputlabel []string) {
 	return []string{"executable", "stdin", "limit"}, []string{"stdout", "stderr", "judgerlog"}
 }
+func (r RunnerStdio) InputKind() []processor.PortKind {
+	return []processor.PortKind{processor.Required, processor.Required, processor.Optional}
+}
+
 func (r RunnerStdio) Run(input []string, output []string) *Result {
-	lim, err := os.ReadFile(input[2])
-	if err != nil {
+	return runInTempDir(func(env processor.Env) *Result {
+		return r.RunEnv(env, input, output)
+	})
+}
+
+// The program is executed in env.Dir.
+func (r RunnerStdio) RunEnv(env processor.Env, input []string, output []string) *Result {
+	return r.RunParams(env, nil, input, output)
+}
+
+func (r RunnerStdio) ParamKeys() []string {
+	return limitParams
+}
+
+func (r RunnerStdio) InputParams() map[string][][]string {
+	return map[string][][]string{
+		"limit": {{"realtime", "cputime"}},
+	}
+}
+
+func (r RunnerStdio) RunParams(env processor.Env, params processor.Params, input []string, output []string) *Result {
+	if err := checkPortParams(r, params, input); err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "open limit: " + err.Error(),
+			Msg:  err.Error(),
 		}
 	}
 	options := []judger.OptionProvider{
 		judger.WithArgument(input[1], output[0], output[1], input[0]),
 		judger.WithJudger(judger.General),
+		judger.WithDir(env.Dir),
 		judger.WithPolicy("builtin:free"),
 		judger.WithLog(output[2], 0, false),
 	}
-	more, err := parseJudgerLimit(string(lim))
+	more, err := readJudgerLimit(input[2])
 	if err != nil {
 		return &Result{
 			Code: processor.RuntimeError,
-			Msg:  "parse judger limit: " + err.Error(),
+			Msg:  err.Error(),
 		}
 	}
 	options = append(options, more...)
-	res, err := judger.Judge(options...)
+	more, err = parseLimitParams(params)
+	if err != nil {
+		return &Result{
+			Code: processor.RuntimeError,
+			Msg:  "parse params: " + err.Error(),
+		}
+	}
+	options = append(options, more...)
+	res, err := judger.JudgeContext(env.Context, options...)
 	if err != nil {
 		return &Result{
 			Code: processor.SystemError,
@@ -51,4 +85,12 @@ func (r RunnerStdio) Run(input []string, output []string) *Result {
 	return res.ProcResult()
 }
 
-var _ Processor = RunnerStdio{}
+func (r RunnerStdio) Cacheable() bool {
+	return false
+}
+
+var _ processor.EnvProcessor = RunnerStdio{}
+var _ processor.ParamProcessor = RunnerStdio{}
+var _ processor.CacheableProcessor = RunnerStdio{}
+var _ processor.PortProcessor = RunnerStdio{}
+var _ processor.ParamPortProcessor = RunnerStdio{}
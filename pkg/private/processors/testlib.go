@@ -0,0 +1,225 @@
+package processors
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Minimal go port of testlib (checker mode), see testlib.h.
+
+type verdict int
+
+const (
+	vOk verdict = iota
+	vWa
+	vPe
+	vFail
+)
+
+// outcome in xml report
+func (r verdict) outcome() string {
+	return [...]string{"accepted", "wrong-answer", "presentation-error", "fail"}[r]
+}
+
+// prefix of the message in stderr
+func (r verdict) errorName() string {
+	return [...]string{"ok ", "wrong answer ", "wrong output format ", "FAIL "}[r]
+}
+
+func (r verdict) exitCode() int {
+	return int(r)
+}
+
+// panicked to stop checking, like quit in testlib
+type checkerQuit struct {
+	verdict verdict
+	msg     string
+}
+
+func quitf(v verdict, format string, a ...any) {
+	panic(checkerQuit{verdict: v, msg: fmt.Sprintf(format, a...)})
+}
+
+// input stream of checker. Errors in streams other than participant's
+// output are reported as fail.
+type stream struct {
+	reader *bufio.Reader
+	output bool
+}
+
+func newStream(r io.Reader, output bool) *stream {
+	return &stream{reader: bufio.NewReaderSize(r, 1<<16), output: output}
+}
+
+func (r *stream) quitf(v verdict, format string, a ...any) {
+	if !r.output {
+		v = vFail
+	}
+	quitf(v, format, a...)
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (r *stream) peek() (byte, bool) {
+	buf, err := r.reader.Peek(1)
+	if err != nil {
+		return 0, false
+	}
+	return buf[0], true
+}
+
+func (r *stream) eof() bool {
+	_, ok := r.peek()
+	return !ok
+}
+
+func (r *stream) skipBlanks() {
+	for {
+		c, err := r.reader.ReadByte()
+		if err != nil {
+			return
+		}
+		if !isBlank(c) {
+			r.reader.UnreadByte()
+			return
+		}
+	}
+}
+
+func (r *stream) seekEof() bool {
+	r.skipBlanks()
+	return r.eof()
+}
+
+func (r *stream) readToken(what string) string {
+	r.skipBlanks()
+	if r.eof() {
+		r.quitf(vPe, "Unexpected end of file - %s expected", what)
+	}
+	var sb strings.Builder
+	for {
+		c, err := r.reader.ReadByte()
+		if err != nil {
+			return sb.String()
+		}
+		if isBlank(c) {
+			r.reader.UnreadByte()
+			return sb.String()
+		}
+		sb.WriteByte(c)
+	}
+}
+
+func (r *stream) readWord() string {
+	return r.readToken("token")
+}
+
+func (r *stream) readLong() int64 {
+	token := r.readToken("int64")
+	val, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		r.quitf(vPe, "Expected int64, but \"%s\" found", compress(token))
+	}
+	return val
+}
+
+func (r *stream) readInt() int32 {
+	token := r.readToken("int32")
+	val, err := strconv.ParseInt(token, 10, 32)
+	if err != nil {
+		r.quitf(vPe, "Expected int32, but \"%s\" found", compress(token))
+	}
+	return int32(val)
+}
+
+func (r *stream) readDouble() float64 {
+	token := r.readToken("double")
+	// only standard notation or e-notation is accepted
+	if strings.Trim(token, "0123456789.eE+-") != "" || strings.Trim(token, ".eE+-") == "" {
+		r.quitf(vPe, "Expected double, but \"%s\" found", compress(token))
+	}
+	val, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		r.quitf(vPe, "Expected double, but \"%s\" found", compress(token))
+	}
+	return val
+}
+
+// Read till the end of line, which is consumed but not included.
+func (r *stream) readLine() string {
+	if r.eof() {
+		r.quitf(vPe, "Unexpected end of file - string expected")
+	}
+	line, _ := r.reader.ReadString('\n')
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+func compress(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "~")
+	if len(s) <= 64 {
+		return s
+	}
+	return s[:30] + "..." + s[len(s)-31:]
+}
+
+func englishEnding(x int) string {
+	x %= 100
+	if x/10 == 1 {
+		return "th"
+	}
+	switch x % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// Run check and return its verdict. As testlib does, accepted output with
+// extra content is regarded as presentation error.
+func runChecker(check func(inf, ouf, ans *stream), inf, ouf, ans *stream) (res checkerQuit) {
+	defer func() {
+		if err := recover(); err != nil {
+			quit, ok := err.(checkerQuit)
+			if !ok {
+				panic(err)
+			}
+			res = quit
+		}
+		if res.verdict == vOk && !ouf.seekEof() {
+			res = checkerQuit{verdict: vPe, msg: "Extra information in the output file"}
+		}
+	}()
+	check(inf, ouf, ans)
+	return checkerQuit{verdict: vFail, msg: "Checker exits without verdict"}
+}
+
+// Write xml report as testlib does with "-appes".
+func writeXmlReport(name string, res checkerQuit) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := encoding.ReplaceUnsupported(charmap.Windows1251.NewEncoder()).Writer(file)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="windows-1251"?><result outcome = "%s">`, res.verdict.outcome())
+	if err := xml.EscapeText(w, []byte(res.msg)); err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(w, "</result>\n")
+	return err
+}
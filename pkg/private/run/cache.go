@@ -1,30 +1,185 @@
 package run
 
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/utils"
+)
+
 type WorkflowCache interface {
-	// hash value of node and its output files
-	Set(hash sha, outputs []string)
-	// nil if no cache
-	Get(hash sha) []string
+	// hash value of node, its output files and result
+	Set(hash sha, outputs []string, result *processor.Result)
+	// Output files of hash, which are placed in dir if the cache needs to
+	// copy them out, and the result. nil if no cache.
+	Get(hash sha, dir string) ([]string, *processor.Result)
 }
 
+// InMemoryCache keeps entries in memory and copies of output files in a
+// temporary directory of its own, so that they remain available after the
+// directory of the run is removed. The zero value is ready to use.
 type InMemoryCache struct {
-	data map[sha][]string
+	mu   sync.Mutex
+	dir  string
+	data map[sha]*memEntry
+}
+
+type memEntry struct {
+	files  []string // "" for missing output
+	result processor.Result
 }
 
 var _ WorkflowCache = (*InMemoryCache)(nil)
 
-func (r *InMemoryCache) Set(hash sha, outputs []string) {
-	if r.data[hash] != nil {
-		panic("multi set")
+// Copy output files into the cache without holding the lock.
+func (r *InMemoryCache) Set(hash sha, outputs []string, result *processor.Result) {
+	r.mu.Lock()
+	_, ok := r.data[hash]
+	if r.dir == "" {
+		dir, err := os.MkdirTemp("", "yaoj-cache-*")
+		if err != nil {
+			r.mu.Unlock()
+			logger.Printf("create cache dir: %v", err)
+			return
+		}
+		r.dir = dir
 	}
-	// logger.Print("\033[32mSet: \033[0m", hash, outputs)
-	r.data[hash] = outputs[:]
+	dir := r.dir
+	r.mu.Unlock()
+	if ok {
+		return
+	}
+
+	files := make([]string, len(outputs))
+	for i, name := range outputs {
+		file := filepath.Join(dir, utils.RandomString(10))
+		if err := copyBlob(name, file); err != nil {
+			if !os.IsNotExist(err) {
+				logger.Printf("cache %s: %v", name, err)
+				removeFiles(files)
+				return
+			}
+			continue
+		}
+		files[i] = file
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.data[hash]; ok {
+		removeFiles(files)
+		return
+	}
+	if r.data == nil {
+		r.data = map[sha]*memEntry{}
+	}
+	r.data[hash] = &memEntry{files: files, result: *result}
 }
 
-func (r *InMemoryCache) Get(hash sha) []string {
-	return r.data[hash][:]
+// Copy cached output files into dir. A broken entry is removed.
+func (r *InMemoryCache) Get(hash sha, dir string) ([]string, *processor.Result) {
+	r.mu.Lock()
+	entry, ok := r.data[hash]
+	r.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	outputs := make([]string, len(entry.files))
+	for i, file := range entry.files {
+		outputs[i] = filepath.Join(dir, utils.RandomString(10))
+		if file == "" {
+			continue
+		}
+		if err := copyBlob(file, outputs[i]); err != nil {
+			logger.Printf("copy from cache: %v", err)
+			removeFiles(outputs)
+			r.mu.Lock()
+			if r.data[hash] == entry {
+				delete(r.data, hash)
+				removeFiles(entry.files)
+			}
+			r.mu.Unlock()
+			return nil, nil
+		}
+	}
+	result := entry.result
+	return outputs, &result
 }
 
-var globalCache = InMemoryCache{
-	data: map[sha][]string{},
+// remove files, ignoring empty names
+func removeFiles(names []string) {
+	for _, name := range names {
+		if name != "" {
+			os.Remove(name)
+		}
+	}
+}
+
+var globalCache = struct {
+	sync.RWMutex
+	cache WorkflowCache
+}{cache: &InMemoryCache{}}
+
+// Set the cache used by all workflows. default: an InMemoryCache
+func SetCache(cache WorkflowCache) {
+	globalCache.Lock()
+	defer globalCache.Unlock()
+	globalCache.cache = cache
+}
+
+func getCache() WorkflowCache {
+	globalCache.RLock()
+	defer globalCache.RUnlock()
+	return globalCache.cache
+}
+
+// nodes with the same hash being calculated
+var inflight = struct {
+	sync.Mutex
+	call map[sha]*sync.WaitGroup
+}{call: map[sha]*sync.WaitGroup{}}
+
+// Fetch outputs and result of hash from cache. If missing, run calc and store
+// them, unless the result is a system error, which may be transient.
+// Concurrent calls with the same hash run calc only once, the others wait
+// for it and are reported as cached. The cache is accessed without holding
+// the lock of inflight calls.
+func cacheFetch(cache WorkflowCache, hash sha, dir string, calc func() ([]string, *processor.Result, error)) (outputs []string, result *processor.Result, cached bool, err error) {
+	for {
+		inflight.Lock()
+		if wg, ok := inflight.call[hash]; ok {
+			inflight.Unlock()
+			wg.Wait()
+			continue
+		}
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		inflight.call[hash] = wg
+		inflight.Unlock()
+
+		return cacheFill(cache, hash, dir, wg, calc)
+	}
+}
+
+// Get outputs and result of hash from cache, or run calc and store them.
+// Waiters are woken up even if calc panics.
+func cacheFill(cache WorkflowCache, hash sha, dir string, wg *sync.WaitGroup, calc func() ([]string, *processor.Result, error)) ([]string, *processor.Result, bool, error) {
+	defer wg.Done()
+	defer func() {
+		inflight.Lock()
+		delete(inflight.call, hash)
+		inflight.Unlock()
+	}()
+
+	if outputs, result := cache.Get(hash, dir); outputs != nil {
+		return outputs, result, true, nil
+	}
+	outputs, result, err := calc()
+	if err == nil && result != nil && result.Code != processor.SystemError {
+		cache.Set(hash, outputs, result)
+	}
+	return outputs, result, false, err
 }
@@ -0,0 +1,115 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	wk "github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+func TestCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.WriteFile(a, []byte("1 2"), 0644)
+	os.WriteFile(b, []byte("1  2"), 0644)
+
+	hashOf := func(procName string, input ...string) sha {
+		node := runtimeNodes(map[string]wk.Node{"x": {ProcName: procName}})["x"]
+		copy(node.Input, input)
+		node.calcHash(node.Processor())
+		return node.hash
+	}
+	wcmp := hashOf("checker:wcmp", a, a, b)
+	if wcmp != hashOf("checker:wcmp", a, a, b) {
+		t.Errorf("expect the same key for the same node")
+	}
+	if wcmp == hashOf("checker:lcmp", a, a, b) {
+		t.Errorf("expect different keys for different processors")
+	}
+	if wcmp == hashOf("checker:wcmp", a, b, a) {
+		t.Errorf("expect different keys for different input positions")
+	}
+
+	node := runtimeNodes(map[string]wk.Node{"x": {ProcName: "inputmaker", Params: processor.Params{"option": "raw"}}})["x"]
+	copy(node.Input, []string{a, a, a})
+	node.calcHash(node.Processor())
+	if node.hash == hashOf("inputmaker", a, a, a) {
+		t.Errorf("expect different keys for different params")
+	}
+
+	if processor.Cacheable(runtimeNodes(map[string]wk.Node{"x": {ProcName: "runner:stdio"}})["x"].Processor()) {
+		t.Errorf("expect runner:stdio not cacheable")
+	}
+}
+
+func TestInMemoryCache(t *testing.T) {
+	work := t.TempDir()
+	a := filepath.Join(work, "a")
+	os.WriteFile(a, []byte("12345"), 0755)
+	var cache InMemoryCache
+	cache.Set(sha{1}, []string{a, filepath.Join(work, "missing")}, &processor.Result{Code: processor.ExitError, Msg: "exit"})
+	// outputs outlive the directory of the run
+	os.RemoveAll(work)
+	os.MkdirAll(work, os.ModePerm)
+
+	outputs, result := cache.Get(sha{1}, work)
+	if len(outputs) != 2 || filepath.Dir(outputs[0]) != work {
+		t.Fatalf("unexpected outputs %v", outputs)
+	}
+	if result == nil || result.Code != processor.ExitError || result.Msg != "exit" {
+		t.Errorf("unexpected result %v", result)
+	}
+	if data, err := os.ReadFile(outputs[0]); err != nil || string(data) != "12345" {
+		t.Errorf("invalid output: %q %v", data, err)
+	}
+	if info, err := os.Stat(outputs[0]); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("invalid mode: %v", err)
+	}
+	if _, err := os.Stat(outputs[1]); !os.IsNotExist(err) {
+		t.Errorf("expect missing output, found %v", err)
+	}
+	if outputs, _ := cache.Get(sha{2}, work); outputs != nil {
+		t.Errorf("expect missed")
+	}
+
+	// broken entry is dropped
+	os.RemoveAll(cache.dir)
+	if outputs, _ := cache.Get(sha{1}, work); outputs != nil {
+		t.Errorf("expect broken entry missed")
+	}
+	if _, ok := cache.data[sha{1}]; ok {
+		t.Errorf("expect broken entry removed")
+	}
+}
+
+func TestCacheFetchPanic(t *testing.T) {
+	cache := &InMemoryCache{}
+	hash := sha{1}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expect panic")
+			}
+		}()
+		cacheFetch(cache, hash, "", func() ([]string, *processor.Result, error) { panic("calc") })
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		outputs, _, cached, err := cacheFetch(cache, hash, "", func() ([]string, *processor.Result, error) {
+			return []string{"a"}, &processor.Result{Code: processor.Ok}, nil
+		})
+		if err != nil || cached || len(outputs) != 1 {
+			t.Errorf("unexpected fetch %v %v %v", outputs, cached, err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("fetch blocked after panic")
+	}
+}
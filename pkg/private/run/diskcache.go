@@ -0,0 +1,406 @@
+package run
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/utils"
+)
+
+// DiskCache stores output files in a content-addressed directory, so that
+// they remain available after the directory of the run is removed, and are
+// reused across restarts. Least recently used entries are evicted once the
+// total size of stored files exceeds the limit.
+//
+// Layout of the directory:
+//
+//	blob/<sha256 of content>.<mode>  content of output files
+//	entry/<hash of node>             json of blob names of outputs and result
+//	tmp/                             files being written
+type DiskCache struct {
+	mu    sync.Mutex
+	dir   string
+	limit int64
+	size  int64
+	lru   *list.List // of *diskEntry, most recently used at front
+	entry map[sha]*list.Element
+	blob  map[string]*diskBlob
+}
+
+type diskEntry struct {
+	hash sha
+	diskEntryData
+	atime time.Time
+}
+
+// content of an entry file
+type diskEntryData struct {
+	Blobs  []string         `json:"blobs"` // "" for missing output
+	Result processor.Result `json:"result"`
+}
+
+type diskBlob struct {
+	size int64
+	ref  int
+}
+
+var _ WorkflowCache = (*DiskCache)(nil)
+
+// Open (or create) a DiskCache in dir, whose total size is limited to limit
+// bytes. Entries already in dir are loaded, and broken ones are removed.
+func NewDiskCache(dir string, limit int64) (*DiskCache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &DiskCache{
+		dir:   dir,
+		limit: limit,
+		lru:   list.New(),
+		entry: map[sha]*list.Element{},
+		blob:  map[string]*diskBlob{},
+	}
+	os.RemoveAll(r.path("tmp"))
+	for _, sub := range []string{"blob", "entry", "tmp"} {
+		if err := os.MkdirAll(r.path(sub), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evict()
+	return r, nil
+}
+
+func (r *DiskCache) path(elem ...string) string {
+	return filepath.Join(append([]string{r.dir}, elem...)...)
+}
+
+// load blobs and entries from the directory
+func (r *DiskCache) load() error {
+	blobs, err := os.ReadDir(r.path("blob"))
+	if err != nil {
+		return err
+	}
+	for _, item := range blobs {
+		info, err := item.Info()
+		if err != nil {
+			return err
+		}
+		r.blob[item.Name()] = &diskBlob{size: info.Size()}
+	}
+
+	entries, err := os.ReadDir(r.path("entry"))
+	if err != nil {
+		return err
+	}
+	loaded := []*diskEntry{}
+	for _, item := range entries {
+		entry, err := r.loadEntry(item)
+		if err != nil {
+			logger.Printf("remove broken cache entry %s: %v", item.Name(), err)
+			os.Remove(r.path("entry", item.Name()))
+			continue
+		}
+		loaded = append(loaded, entry)
+	}
+	// least recently used at back
+	for _, entry := range loaded {
+		elem := r.lru.Front()
+		for elem != nil && elem.Value.(*diskEntry).atime.After(entry.atime) {
+			elem = elem.Next()
+		}
+		if elem == nil {
+			r.entry[entry.hash] = r.lru.PushBack(entry)
+		} else {
+			r.entry[entry.hash] = r.lru.InsertBefore(entry, elem)
+		}
+		for _, name := range entry.Blobs {
+			if name != "" {
+				r.blob[name].ref++
+			}
+		}
+	}
+
+	for name, blob := range r.blob {
+		if blob.ref == 0 {
+			os.Remove(r.path("blob", name))
+			delete(r.blob, name)
+			continue
+		}
+		r.size += blob.size
+	}
+	return nil
+}
+
+func (r *DiskCache) loadEntry(item os.DirEntry) (*diskEntry, error) {
+	b, err := hex.DecodeString(item.Name())
+	if err != nil || len(b) != len(sha{}) {
+		return nil, fmt.Errorf("invalid name")
+	}
+	info, err := item.Info()
+	if err != nil {
+		return nil, err
+	}
+	entry := &diskEntry{atime: info.ModTime()}
+	copy(entry.hash[:], b)
+	data, err := os.ReadFile(r.path("entry", item.Name()))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entry.diskEntryData); err != nil {
+		return nil, err
+	}
+	for _, name := range entry.Blobs {
+		if _, ok := r.blob[name]; name != "" && !ok {
+			return nil, fmt.Errorf("blob %s not found", name)
+		}
+	}
+	return entry, nil
+}
+
+// Copy output files into the cache. Missing outputs are recorded as well.
+// Files are copied without holding the lock.
+func (r *DiskCache) Set(hash sha, outputs []string, result *processor.Result) {
+	r.mu.Lock()
+	_, ok := r.entry[hash]
+	r.mu.Unlock()
+	if ok {
+		return
+	}
+	pending := make([]*pendingBlob, len(outputs))
+	defer func() {
+		for _, p := range pending {
+			if p != nil {
+				os.Remove(p.tmp)
+			}
+		}
+	}()
+	for i, name := range outputs {
+		p, err := r.writeBlob(name)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Printf("cache %s: %v", name, err)
+				return
+			}
+			continue
+		}
+		pending[i] = p
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entry[hash]; ok {
+		return
+	}
+	entry := &diskEntry{hash: hash, atime: time.Now()}
+	entry.Blobs, entry.Result = make([]string, len(outputs)), *result
+	for i, p := range pending {
+		if p == nil {
+			continue
+		}
+		if err := r.addBlob(p); err != nil {
+			logger.Printf("cache %s: %v", outputs[i], err)
+			r.release(entry.Blobs)
+			return
+		}
+		entry.Blobs[i] = p.name
+	}
+	data, _ := json.Marshal(entry.diskEntryData)
+	if err := r.writeFile(r.path("entry", hash.String()), data, 0644); err != nil {
+		logger.Printf("cache entry: %v", err)
+		r.release(entry.Blobs)
+		return
+	}
+	r.entry[hash] = r.lru.PushFront(entry)
+	r.evict()
+}
+
+// Copy cached output files into dir. Blobs are referenced while being copied
+// without holding the lock, so that they are not removed by eviction. A broken
+// entry is removed.
+func (r *DiskCache) Get(hash sha, dir string) ([]string, *processor.Result) {
+	r.mu.Lock()
+	elem, ok := r.entry[hash]
+	if !ok {
+		r.mu.Unlock()
+		return nil, nil
+	}
+	entry := elem.Value.(*diskEntry)
+	blobs := append([]string{}, entry.Blobs...)
+	result := entry.Result
+	for _, name := range blobs {
+		if blob, ok := r.blob[name]; ok {
+			blob.ref++
+		}
+	}
+	r.mu.Unlock()
+
+	outputs := make([]string, len(blobs))
+	var err error
+	for i, blob := range blobs {
+		outputs[i] = filepath.Join(dir, utils.RandomString(10))
+		if blob == "" {
+			continue
+		}
+		if err = copyBlob(r.path("blob", blob), outputs[i]); err != nil {
+			logger.Printf("copy from cache: %v", err)
+			break
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.release(blobs)
+	if err != nil {
+		for _, name := range outputs {
+			os.Remove(name)
+		}
+		if r.entry[hash] == elem {
+			r.remove(elem)
+		}
+		return nil, nil
+	}
+	if r.entry[hash] == elem {
+		entry.atime = time.Now()
+		os.Chtimes(r.path("entry", hash.String()), entry.atime, entry.atime)
+		r.lru.MoveToFront(elem)
+	}
+	return outputs, &result
+}
+
+// content of a file copied to tmp, not yet added as a blob
+type pendingBlob struct {
+	name string
+	tmp  string
+	size int64
+}
+
+// Copy content of file name to a temporary file.
+func (r *DiskCache) writeBlob(name string) (*pendingBlob, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(r.path("tmp"), "blob-*")
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), file)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &pendingBlob{
+		name: fmt.Sprintf("%x.%o", hash.Sum(nil), info.Mode().Perm()),
+		tmp:  tmp.Name(),
+		size: info.Size(),
+	}, nil
+}
+
+// Store the pending blob (increasing its reference count).
+func (r *DiskCache) addBlob(p *pendingBlob) error {
+	if b, ok := r.blob[p.name]; ok {
+		b.ref++
+		return nil
+	}
+	if err := os.Rename(p.tmp, r.path("blob", p.name)); err != nil {
+		return err
+	}
+	r.blob[p.name] = &diskBlob{size: p.size, ref: 1}
+	r.size += p.size
+	return nil
+}
+
+// decrease reference count of blobs, removing unused ones
+func (r *DiskCache) release(blobs []string) {
+	for _, name := range blobs {
+		blob, ok := r.blob[name]
+		if !ok {
+			continue
+		}
+		blob.ref--
+		if blob.ref == 0 {
+			os.Remove(r.path("blob", name))
+			delete(r.blob, name)
+			r.size -= blob.size
+		}
+	}
+}
+
+// remove least recently used entries until size is within limit
+func (r *DiskCache) evict() {
+	for r.size > r.limit && r.lru.Len() > 0 {
+		r.remove(r.lru.Back())
+	}
+}
+
+func (r *DiskCache) remove(elem *list.Element) {
+	entry := r.lru.Remove(elem).(*diskEntry)
+	delete(r.entry, entry.hash)
+	os.Remove(r.path("entry", entry.hash.String()))
+	r.release(entry.Blobs)
+}
+
+// write file atomically
+func (r *DiskCache) writeFile(name string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(r.path("tmp"), "file-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Total size of files in the cache.
+func (r *DiskCache) Size() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// copy blob to dst with its mode
+func copyBlob(blob, dst string) error {
+	info, err := os.Stat(blob)
+	if err != nil {
+		return err
+	}
+	if _, err := utils.CopyFile(blob, dst); err != nil {
+		return err
+	}
+	return os.Chmod(dst, info.Mode().Perm())
+}
@@ -0,0 +1,91 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir, work := t.TempDir(), t.TempDir()
+	write := func(name, content string) string {
+		name = filepath.Join(work, name)
+		if err := os.WriteFile(name, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	cache, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := sha{1}, sha{2}
+	ok := &processor.Result{Code: processor.Ok}
+	cache.Set(a, []string{write("a", "12345"), filepath.Join(work, "missing")}, &processor.Result{Code: processor.ExitError, Msg: "exit"})
+	os.RemoveAll(work)
+	os.MkdirAll(work, os.ModePerm)
+
+	outputs, result := cache.Get(a, work)
+	if len(outputs) != 2 {
+		t.Fatalf("expect 2 outputs, found %v", outputs)
+	}
+	if result == nil || result.Code != processor.ExitError || result.Msg != "exit" {
+		t.Errorf("unexpected result %v", result)
+	}
+	if data, err := os.ReadFile(outputs[0]); err != nil || string(data) != "12345" {
+		t.Errorf("invalid output: %q %v", data, err)
+	}
+	if info, err := os.Stat(outputs[0]); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("invalid mode: %v", err)
+	}
+	if _, err := os.Stat(outputs[1]); !os.IsNotExist(err) {
+		t.Errorf("expect missing output, found %v", err)
+	}
+
+	// same content is stored once
+	cache.Set(b, []string{write("b", "12345")}, ok)
+	if cache.Size() != 5 {
+		t.Errorf("expect size 5, found %d", cache.Size())
+	}
+	cache.Set(sha{3}, []string{write("c", "abcd")}, ok)
+
+	// reopen: a is used more recently than c, so c is evicted
+	cache.Get(a, work)
+	cache, err = NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Set(sha{4}, []string{write("d", "xy")}, ok)
+	if outputs, _ := cache.Get(sha{3}, work); outputs != nil {
+		t.Errorf("expect c evicted")
+	}
+	outputs, result = cache.Get(a, work)
+	if outputs == nil || result.Code != processor.ExitError {
+		t.Errorf("expect a cached with its result, found %v", result)
+	}
+	if outputs, _ := cache.Get(sha{4}, work); outputs == nil {
+		t.Errorf("expect d cached")
+	}
+	if cache.Size() != 7 {
+		t.Errorf("expect size 7, found %d", cache.Size())
+	}
+
+	// broken entry is dropped, so that it can be set again
+	blobs, _ := os.ReadDir(filepath.Join(dir, "blob"))
+	for _, blob := range blobs {
+		os.Remove(filepath.Join(dir, "blob", blob.Name()))
+	}
+	files, _ := os.ReadDir(work)
+	if outputs, _ := cache.Get(a, work); outputs != nil {
+		t.Errorf("expect broken entry missed")
+	}
+	if left, _ := os.ReadDir(work); len(left) != len(files) {
+		t.Errorf("expect copied files removed")
+	}
+	cache.Set(a, []string{write("a", "12345")}, ok)
+	if outputs, _ := cache.Get(a, work); len(outputs) != 1 {
+		t.Errorf("expect entry set again, found %v", outputs)
+	}
+}
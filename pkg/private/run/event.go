@@ -0,0 +1,57 @@
+package run
+
+import (
+	"sync"
+
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	wk "github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+type EventType string
+
+const (
+	EventRunStarted      EventType = "run_started"
+	EventNodeStarted     EventType = "node_started"
+	EventNodeFinished    EventType = "node_finished"
+	EventTestFinished    EventType = "test_finished"
+	EventSubtaskFinished EventType = "subtask_finished"
+	EventRunFinished     EventType = "run_finished"
+)
+
+// Event emitted during a judgement. Fields not related to Type are zero.
+type Event struct {
+	Type EventType `json:"type"`
+	// index of the subtask (0 if not subtask) and the testcase in it
+	Subtask  int `json:"subtask"`
+	Testcase int `json:"testcase"`
+	// node events
+	Node string `json:"node,omitempty"`
+	// restored from the cache if Cached
+	NodeResult *processor.Result `json:"node_result,omitempty"`
+	Cached     bool              `json:"cached,omitempty"`
+	// EventTestFinished
+	TestResult *wk.Result `json:"test_result,omitempty"`
+	// EventSubtaskFinished, which is emitted for subtask problems only
+	SubtaskResult *problem.SubtResult `json:"subtask_result,omitempty"`
+	// EventRunFinished
+	Result *problem.Result `json:"result,omitempty"`
+}
+
+// Observer receives events of a judgement in the order they happen. Calls
+// never overlap, so it needs not to be concurrent-safe, but it should return
+// quickly as judging waits for it.
+type Observer func(event Event)
+
+// serialize calls of observer
+func syncObserver(observer Observer) Observer {
+	if observer == nil {
+		return func(Event) {}
+	}
+	var mu sync.Mutex
+	return func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		observer(event)
+	}
+}
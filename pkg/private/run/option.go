@@ -0,0 +1,104 @@
+package run
+
+import (
+	"runtime"
+
+	wk "github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+type Option struct {
+	// maximum number of nodes of a workflow running at the same time
+	Parallel int
+	// receiving events, nil for none
+	Observer Observer
+	// when to stop judging, default: Full
+	Strategy Strategy
+	// maximum number of testcases of a problem running at the same time
+	TestWorkers int
+	// data of datagroups defined by the workflow: map[group]map[field]path
+	Groups map[wk.Groupname]map[string]string
+	// position of the testcase being run, reported in events
+	subtask, testcase int
+}
+
+type OptionProvider func(*Option)
+
+// Strategy decides which testcases are skipped after a failure (testcase
+// not accepted). Skipped testcases have result titled "Skipped".
+type Strategy int
+
+const (
+	// run all testcases (OI style)
+	Full Strategy = iota
+	// skip all remaining testcases after a failure (ICPC style)
+	StopOnFirstFailure
+	// skip remaining testcases of a subtask after a failure in it, if the
+	// problem has subtasks and CalcMethod is Mmin. Same as Full otherwise.
+	StopSubtaskOnFailure
+)
+
+func newOption(options ...OptionProvider) Option {
+	var option = Option{
+		Parallel: runtime.NumCPU(),
+	}
+	for _, v := range options {
+		v(&option)
+	}
+	if option.Parallel <= 0 {
+		option.Parallel = 1
+	}
+	if option.TestWorkers <= 0 {
+		option.TestWorkers = 1
+	}
+	option.Observer = syncObserver(option.Observer)
+	return option
+}
+
+// Set the maximum number of nodes running concurrently in a workflow.
+// default: runtime.NumCPU()
+func WithParallel(n int) OptionProvider {
+	return func(o *Option) {
+		o.Parallel = n
+	}
+}
+
+// Set the judging strategy. default: Full
+func WithStrategy(strategy Strategy) OptionProvider {
+	return func(o *Option) {
+		o.Strategy = strategy
+	}
+}
+
+// Set the maximum number of testcases running concurrently in a problem.
+// Each of them runs in its own directory, and results (as well as events of
+// testcases) are reported in the same order as running one by one.
+// default: 1
+func WithTestWorkers(n int) OptionProvider {
+	return func(o *Option) {
+		o.TestWorkers = n
+	}
+}
+
+// Provide data of a datagroup defined by the workflow (not built-in), e.g.
+// "hack" for hack inputs, shared by all testcases of a problem.
+func WithGroup(group wk.Groupname, data map[string]string) OptionProvider {
+	return func(o *Option) {
+		if o.Groups == nil {
+			o.Groups = map[wk.Groupname]map[string]string{}
+		}
+		o.Groups[group] = data
+	}
+}
+
+// Receive progress events of the judgement, see Event.
+func WithObserver(observer Observer) OptionProvider {
+	return func(o *Option) {
+		o.Observer = observer
+	}
+}
+
+func atTestcase(subtask, testcase int) OptionProvider {
+	return func(o *Option) {
+		o.subtask, o.testcase = subtask, testcase
+	}
+}
@@ -0,0 +1,131 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	wk "github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+// Failure of a node independent of submission, found by PrepareProblem.
+type PrepareError struct {
+	// index of the subtask (0 if not subtask) and the testcase in it
+	Subtask, Testcase int
+	Node              string
+	Result            processor.Result
+	// position of the testcase in judging order
+	index int
+}
+
+func (r PrepareError) Error() string {
+	return fmt.Sprintf("subtask %d testcase %d: node[%s]: %s", r.Subtask, r.Testcase, r.Node, r.Result.Msg)
+}
+
+// All failures found by PrepareProblem, in judging order.
+type PrepareErrors []PrepareError
+
+func (r PrepareErrors) Error() string {
+	msgs := []string{}
+	for _, err := range r {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Run nodes independent of the submission (e.g. compiling checker, making
+// inputs) for every testcase in dir, so that their outputs are cached before
+// any submission. Failures of these nodes, which are data errors of the
+// problem, are returned as PrepareErrors. WithTestWorkers applies as well.
+// Nodes depending on datagroups defined by the workflow are skipped unless
+// their data is given by WithGroup.
+func PrepareProblem(ctx context.Context, r *problem.ProbData, dir string, options ...OptionProvider) error {
+	logger.Printf("prepare dir=%s", dir)
+	option := newOption(options...)
+	// nodes depending on data given by callers are skipped
+	skip := r.Workflow().DependOn(wk.Gsubm)
+	for _, group := range r.Workflow().Group {
+		if _, ok := option.Groups[group]; ok {
+			continue
+		}
+		for name := range r.Workflow().DependOn(group) {
+			skip[name] = true
+		}
+	}
+	if err := checkGroups(r.Workflow(), option.Groups, skip); err != nil {
+		return err
+	}
+	groups, err := testGroups(r, nil, option.Groups)
+	if err != nil {
+		return err
+	}
+
+	jobs := []*testJob{}
+	for _, group := range groups {
+		jobs = append(jobs, group.jobs...)
+	}
+	var mu sync.Mutex
+	failures := PrepareErrors{}
+	var firstErr error
+	sem := make(chan struct{}, option.TestWorkers)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		job := job
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			nodes, err := func() (map[string]*rtNode, error) {
+				testDir, err := os.MkdirTemp(dir, "prepare-*")
+				if err != nil {
+					return nil, err
+				}
+				jobOptions := append([]OptionProvider{}, options...)
+				jobOptions = append(jobOptions, atTestcase(job.subtask, job.testcase))
+				return runNodes(ctx, r.Workflow(), testDir, job.inbound, skip, newOption(jobOptions...))
+			}()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for name, node := range nodes {
+				if node.Result != nil && node.Result.Code != processor.Ok {
+					failures = append(failures, PrepareError{
+						Subtask:  job.subtask,
+						Testcase: job.testcase,
+						Node:     name,
+						Result:   *node.Result,
+						index:    job.index,
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool {
+			a, b := failures[i], failures[j]
+			if a.index != b.index {
+				return a.index < b.index
+			}
+			return a.Node < b.Node
+		})
+		return failures
+	}
+	return nil
+}
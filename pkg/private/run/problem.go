@@ -1,7 +1,9 @@
 package run
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"path"
 	"strconv"
 
@@ -27,66 +29,291 @@ func testcaseOf(r *problem.ProbData, subtaskid string) []map[string]string {
 	return res
 }
 
-// Run all testcase in the dir.
-func RunProblem(r *problem.ProbData, dir string, submission map[string]string) (*problem.Result, error) {
+// result of a testcase not being run
+func skippedResult(fullscore float64) workflow.Result {
+	return workflow.Result{
+		ResultMeta: workflow.ResultMeta{
+			Title:     "Skipped",
+			Score:     0,
+			Fullscore: fullscore,
+		},
+		File: []workflow.ResultFileDisplay{},
+	}
+}
+
+// result of a testcase interrupted or not run due to cancellation
+func cancelledResult(fullscore float64) workflow.Result {
+	res := skippedResult(fullscore)
+	res.Title = "Cancelled"
+	return res
+}
+
+// Run all testcase in the dir. Subtasks are run after those they depend on,
+// and skipped if any of them is not accepted. See Strategy for testcases
+// skipped after a failure.
+func RunProblem(r *problem.ProbData, dir string, submission map[string]string,
+	options ...OptionProvider) (*problem.Result, error) {
+	return RunProblemContext(context.Background(), r, dir, submission, options...)
+}
+
+// Same as RunProblem, but once ctx is done, running processes are killed and
+// remaining testcases are not run. In this case a partial result marked as
+// Cancelled is returned along with ctx.Err().
+func RunProblemContext(ctx context.Context, r *problem.ProbData, dir string, submission map[string]string,
+	options ...OptionProvider) (*problem.Result, error) {
 	logger.Printf("run dir=%s", dir)
+	option := newOption(options...)
+	// observer is serialized once for all workflows
+	options = append(options, WithObserver(option.Observer))
 	// check submission
 	for k := range r.Submission {
 		if _, ok := submission[k]; !ok {
 			return nil, fmt.Errorf("submission missing field %s", k)
 		}
 	}
-
-	var inboundPath = map[workflow.Groupname]*map[string]string{
-		workflow.Gsubm: (*map[string]string)(&submission),
+	if err := checkGroups(r.Workflow(), option.Groups, nil); err != nil {
+		return nil, err
+	}
+	groups, err := testGroups(r, submission, option.Groups)
+	if err != nil {
+		return nil, err
 	}
-	inboundPath[workflow.Gstatic] = toPathMap(r, r.Static)
+
 	var result = problem.Result{
-		IsSubtask: r.IsSubtask(),
-		Subtask:   []problem.SubtResult{},
+		IsSubtask:  r.IsSubtask(),
+		CalcMethod: r.CalcMethod,
+		Fullscore:  r.Fullscore,
+		Subtask:    []problem.SubtResult{},
 	}
 	if r.IsSubtask() {
-		for _, subtask := range r.Subtasks.Record {
-			sub_res := problem.SubtResult{
-				Subtaskid: subtask["_subtaskid"],
-				Testcase:  []workflow.Result{},
+		result.Subtask = make([]problem.SubtResult, len(r.Subtasks.Record))
+	}
+	option.Observer(Event{Type: EventRunStarted})
+
+	// testcases in the order they are judged
+	jobs := []*testJob{}
+	for _, group := range groups {
+		jobs = append(jobs, group.jobs...)
+	}
+	// wait for testcases running ahead on return
+	defer func() {
+		for _, job := range jobs {
+			if job.done != nil {
+				job.cancel()
+				<-job.done
 			}
-			inboundPath[workflow.Gsubt] = toPathMap(r, subtask)
-			tests := testcaseOf(r, subtask["_subtaskid"])
-			score, err := strconv.ParseFloat(subtask["_score"], 64)
+		}
+	}()
+	launch := func(job *testJob) {
+		jobCtx, cancel := context.WithCancel(ctx)
+		job.cancel, job.done = cancel, make(chan struct{})
+		go func() {
+			defer close(job.done)
+			// every testcase owns a run directory
+			testDir, err := os.MkdirTemp(dir, "test-*")
 			if err != nil {
-				return nil, err
+				job.err = err
+				return
 			}
-			sub_res.Fullscore = score
-			for _, test := range tests {
-				inboundPath[workflow.Gtests] = toPathMap(r, test)
-				res, err := RunWorkflow(r.Workflow(), dir, inboundPath, score/float64(len(tests)))
-				if err != nil {
-					return nil, err
+			jobOptions := append([]OptionProvider{}, options...)
+			jobOptions = append(jobOptions, atTestcase(job.subtask, job.testcase))
+			job.res, job.err = RunWorkflowContext(jobCtx, r.Workflow(), testDir, job.inbound,
+				job.fullscore, jobOptions...)
+		}()
+	}
+
+	// whether any testcase is not accepted
+	failed := false
+	subtFailed := map[int]bool{}
+	finished := map[int]bool{}
+	// Whether job is skipped according to testcases judged. Once it holds, it
+	// holds afterwards.
+	skipped := func(job *testJob) bool {
+		if option.Strategy == StopOnFirstFailure && failed {
+			return true
+		}
+		if option.Strategy == StopSubtaskOnFailure && r.IsSubtask() && r.CalcMethod == problem.Mmin && subtFailed[job.subtask] {
+			return true
+		}
+		// skipped if any subtask it depends on is not accepted
+		for _, dep := range job.depend {
+			if finished[dep] && result.Subtask[dep].Title != "Accepted" {
+				return true
+			}
+		}
+		return false
+	}
+
+	next := 0
+	for _, group := range groups {
+		sub_res := problem.SubtResult{
+			Subtaskid: group.subtaskid,
+			Fullscore: group.fullscore,
+			Testcase:  []workflow.Result{},
+			Depend:    group.depend,
+		}
+		for _, job := range group.jobs {
+			// testcases are run ahead with at most option.TestWorkers of them
+			// running, which are cancelled if turn out to be skipped
+			for ; next < len(jobs) && next < job.index+option.TestWorkers; next++ {
+				if !skipped(jobs[next]) {
+					launch(jobs[next])
 				}
-				sub_res.Testcase = append(sub_res.Testcase, *res)
 			}
+			var res workflow.Result
+			if ctx.Err() == nil && skipped(job) {
+				if job.done != nil {
+					job.cancel()
+					<-job.done
+				}
+				res = skippedResult(job.fullscore)
+			} else {
+				if job.done == nil {
+					launch(job)
+				}
+				<-job.done
+				if job.err != nil {
+					if ctx.Err() == nil {
+						return nil, job.err
+					}
+					res = cancelledResult(job.fullscore)
+				} else {
+					res = *job.res
+				}
+			}
+			sub_res.Testcase = append(sub_res.Testcase, res)
+			option.Observer(Event{Type: EventTestFinished, Subtask: job.subtask, Testcase: job.testcase, TestResult: &res})
+			if res.Title != "Accepted" && res.Title != "Skipped" {
+				failed = true
+				subtFailed[job.subtask] = true
+			}
+		}
+		if !r.IsSubtask() {
 			result.Subtask = append(result.Subtask, sub_res)
+			continue
 		}
-	} else {
-		sub_res := problem.SubtResult{
-			Testcase: []workflow.Result{},
+		sub_res.Calc(r.CalcMethod)
+		result.Subtask[group.subtask] = sub_res
+		finished[group.subtask] = true
+		option.Observer(Event{Type: EventSubtaskFinished, Subtask: group.subtask, SubtaskResult: &sub_res})
+	}
+	result.Cancelled = ctx.Err() != nil
+	result.Calc()
+	option.Observer(Event{Type: EventRunFinished, Result: &result})
+	if result.Cancelled {
+		return &result, ctx.Err()
+	}
+	return &result, nil
+}
+
+// a testcase to be judged
+type testJob struct {
+	// position in judging order
+	index             int
+	subtask, testcase int
+	// subtasks the testcase depends on
+	depend    []int
+	fullscore float64
+	inbound   map[workflow.Groupname]*map[string]string
+	// set once launched
+	done   chan struct{}
+	cancel context.CancelFunc
+	res    *workflow.Result
+	err    error
+}
+
+// testcases of a subtask (or all testcases if not subtask)
+type testGroup struct {
+	subtask   int
+	subtaskid string
+	fullscore float64
+	depend    []int
+	jobs      []*testJob
+}
+
+// Check that data of datagroups defined by the workflow are given, except
+// those only used by nodes in skip.
+func checkGroups(w workflow.Workflow, data map[workflow.Groupname]map[string]string, skip map[string]bool) error {
+	for group := range data {
+		if !w.HasGroup(group) || group.Builtin() {
+			return fmt.Errorf("datagroup %s not defined by workflow", group)
+		}
+	}
+	for group, fields := range w.Inbound {
+		if group.Builtin() || fields == nil {
+			continue
 		}
-		for _, test := range r.Tests.Record {
-			inboundPath[workflow.Gtests] = toPathMap(r, test)
+		for field, bounds := range *fields {
+			for _, bound := range bounds {
+				if _, ok := data[group][field]; !ok && !skip[bound.Name] {
+					return fmt.Errorf("datagroup %s missing field %s", group, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Group testcases by subtasks in the order they are judged, that is, a
+// subtask comes after those it depends on. Datagroups defined by the
+// workflow are given by extra.
+func testGroups(r *problem.ProbData, submission map[string]string,
+	extra map[workflow.Groupname]map[string]string) ([]testGroup, error) {
+	inbound := func(subtask, test map[string]string) map[workflow.Groupname]*map[string]string {
+		res := map[workflow.Groupname]*map[string]string{
+			workflow.Gsubm:   (*map[string]string)(&submission),
+			workflow.Gstatic: toPathMap(r, r.Static),
+			workflow.Gtests:  toPathMap(r, test),
+		}
+		for group, data := range extra {
+			data := data
+			res[group] = &data
+		}
+		if subtask != nil {
+			res[workflow.Gsubt] = toPathMap(r, subtask)
+		}
+		return res
+	}
+	index := 0
+	newJob := func(subtask, testcase int, fullscore float64, depend []int) *testJob {
+		index++
+		return &testJob{index: index - 1, subtask: subtask, testcase: testcase, fullscore: fullscore, depend: depend}
+	}
 
+	if !r.IsSubtask() {
+		group := testGroup{fullscore: r.Fullscore}
+		for j, test := range r.Tests.Record {
 			score := r.Fullscore / float64(len(r.Tests.Record))
 			if f, err := strconv.ParseFloat(test["_score"], 64); err == nil {
 				score = f
 			}
-			res, err := RunWorkflow(r.Workflow(), dir, inboundPath, score)
-			if err != nil {
-				return nil, err
-			}
-			sub_res.Testcase = append(sub_res.Testcase, *res)
+			job := newJob(0, j, score, nil)
+			job.inbound = inbound(nil, test)
+			group.jobs = append(group.jobs, job)
 		}
-		sub_res.Fullscore = r.Fullscore
-		result.Subtask = append(result.Subtask, sub_res)
+		return []testGroup{group}, nil
 	}
-	return &result, nil
+
+	order, err := r.SubtaskOrder()
+	if err != nil {
+		return nil, err
+	}
+	depend := r.SubtaskDepend()
+	groups := []testGroup{}
+	for _, i := range order {
+		subtask := r.Subtasks.Record[i]
+		score, err := strconv.ParseFloat(subtask["_score"], 64)
+		if err != nil {
+			return nil, err
+		}
+		group := testGroup{subtask: i, subtaskid: subtask["_subtaskid"], fullscore: score, depend: depend[i]}
+		tests := testcaseOf(r, subtask["_subtaskid"])
+		for j, test := range tests {
+			job := newJob(i, j, score/float64(len(tests)), depend[i])
+			job.inbound = inbound(subtask, test)
+			group.jobs = append(group.jobs, job)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
 }
@@ -1,11 +1,15 @@
 package run
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 
 	"github.com/k0kubun/pp/v3"
 	"github.com/sshwy/yaoj-core/pkg/private/processors"
@@ -17,8 +21,39 @@ import (
 // perform a workflow in a directory.
 // inboundPath: map[datagroup_name]*map[field]filename
 func RunWorkflow(w wk.Workflow, dir string, inboundPath map[wk.Groupname]*map[string]string,
-	fullscore float64) (*wk.Result, error) {
+	fullscore float64, options ...OptionProvider) (*wk.Result, error) {
+	return RunWorkflowContext(context.Background(), w, dir, inboundPath, fullscore, options...)
+}
+
+// Same as RunWorkflow, but once ctx is done, running processes are killed,
+// remaining nodes are not run and ctx.Err() is returned.
+func RunWorkflowContext(ctx context.Context, w wk.Workflow, dir string, inboundPath map[wk.Groupname]*map[string]string,
+	fullscore float64, options ...OptionProvider) (*wk.Result, error) {
+	nodes, err := runNodes(ctx, w, dir, inboundPath, nil, newOption(options...))
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeNodes := map[string]wk.RuntimeNode{}
+	for name, node := range nodes {
+		runtimeNodes[name] = node.RuntimeNode
+	}
+	res := w.Analyze(w, runtimeNodes, fullscore)
+
+	// bs, _ := script.Exec("ls .").Bytes()
+	// logger.Print(string(bs))
+	return &res, nil
+}
+
+// Run nodes of the workflow except those in skip, whose inputs are not
+// required.
+func runNodes(ctx context.Context, w wk.Workflow, dir string, inboundPath map[wk.Groupname]*map[string]string,
+	skip map[string]bool, option Option) (map[string]*rtNode, error) {
 	nodes := runtimeNodes(w.Node)
+	slots := newInputSlots(w.WorkflowGraph)
+	for name, node := range nodes {
+		node.Input = make([]string, slots.size[name])
+	}
 
 	// if len(w.Inbound) != len(inboundPath) {
 	// 	return nil, fmt.Errorf("invalid inboundPath: missing field")
@@ -28,78 +63,123 @@ func RunWorkflow(w wk.Workflow, dir string, inboundPath map[wk.Groupname]*map[st
 			return nil, fmt.Errorf("w.Inbound[%s] == nil", i)
 		}
 		data := inboundPath[i]
-		if data == nil {
-			return nil, fmt.Errorf("inboundPath[%s] == nil", i)
-		}
 		for j, bounds := range *group {
-			if _, ok := (*data)[j]; !ok {
-				return nil, fmt.Errorf("invalid inboundPath: missing field %s %s", i, j)
-			}
-			for _, bound := range bounds {
-				nodes[bound.Name].Input[bound.LabelIndex] = (*inboundPath[i])[j]
+			for k, bound := range bounds {
+				if skip[bound.Name] {
+					continue
+				}
+				if data == nil {
+					return nil, fmt.Errorf("inboundPath[%s] == nil", i)
+				}
+				name, ok := (*data)[j]
+				if !ok {
+					return nil, fmt.Errorf("invalid inboundPath: missing field %s %s", i, j)
+				}
+				// processors run in their own directories, where relative
+				// paths are resolved otherwise
+				if name != "" {
+					abs, err := filepath.Abs(name)
+					if err != nil {
+						return nil, err
+					}
+					name = abs
+				}
+				nodes[bound.Name].Input[slots.inbound[i][j][k]] = name
 			}
 		}
 	}
 
-	previousWd, err := os.Getwd()
+	dir, err := filepath.Abs(dir)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		logger.Printf("Chdir back to %q", previousWd)
-		os.Chdir(previousWd)
-	}()
-
-	err = os.Chdir(dir)
-	if err != nil {
-		return nil, err
+	for i, constant := range w.Constant {
+		if skip[constant.To.Name] {
+			continue
+		}
+		name := path.Join(dir, utils.RandomString(10))
+		if err := os.WriteFile(name, []byte(constant.Value), 0644); err != nil {
+			return nil, err
+		}
+		nodes[constant.To.Name].Input[slots.constant[i]] = name
 	}
-	logger.Printf("Chdir %q", dir)
 
-	err = topologicalEnum(w, func(id string) error {
+	err = parallelEnum(w, option.Parallel, func(id string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if skip[id] {
+			return nil
+		}
 		node := nodes[id]
 		if !node.inputFullfilled() {
 			return fmt.Errorf("input not fullfilled")
 		}
-		node.calcHash()
-		cache_outputs := globalCache.Get(node.hash)
-
-		if cache_outputs != nil {
-			logger.Printf("Run node[%s] (cached)", id)
-			node.Output = cache_outputs
-			node.Result = nil
-		} else {
-			// log.Printf("%d, %v", id, node.hash)
+		proc := node.Processor()
+		if proc == nil {
+			return fmt.Errorf("node[%s]: unknown processor %q", id, node.ProcName)
+		}
+		option.Observer(Event{Type: EventNodeStarted, Subtask: option.subtask, Testcase: option.testcase, Node: id})
+		calc := func() ([]string, *processor.Result, error) {
 			for i := 0; i < len(node.Output); i++ {
-				node.Output[i] = utils.RandomString(10)
+				node.Output[i] = path.Join(dir, utils.RandomString(10))
+			}
+			// every node owns a working directory, so that concurrent nodes
+			// never share temporary files
+			nodeDir, err := os.MkdirTemp(dir, "node-*")
+			if err != nil {
+				return nil, nil, err
 			}
 			logger.Printf("Run node[%s] no cache", id)
 			// logger.Printf("input %+v", node.Input)
 			// logger.Printf("output %+v", node.Output)
-			result := node.Processor().Run(node.Input, node.Output)
-
+			node.Result = processor.RunWithParams(proc, processor.Env{Dir: nodeDir, Context: ctx},
+				node.Params, node.Input, node.Output)
+			// outputs of killed processes are never cached
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			return node.Output, node.Result, nil
+		}
+		var outputs []string
+		var result *processor.Result
+		var cached bool
+		var err error
+		if processor.Cacheable(proc) {
+			node.calcHash(proc)
+			outputs, result, cached, err = cacheFetch(getCache(), node.hash, dir, calc)
+		} else {
+			_, _, err = calc()
+		}
+		if err != nil {
+			return err
+		}
+		// a cached node reproduces the result, e.g. a failed compilation
+		if cached {
+			logger.Printf("Run node[%s] (cached)", id)
+			node.Output = outputs
 			node.Result = result
-			globalCache.Set(node.hash, node.Output)
 		}
-		nodes[id] = node
-		for _, edge := range w.EdgeFrom(id) {
-			nodes[edge.To.Name].Input[edge.To.LabelIndex] = nodes[edge.From.Name].Output[edge.From.LabelIndex]
+		option.Observer(Event{
+			Type:       EventNodeFinished,
+			Subtask:    option.subtask,
+			Testcase:   option.testcase,
+			Node:       id,
+			NodeResult: node.Result,
+			Cached:     cached,
+		})
+		// destinations are not running until all their sources finish
+		for i, edge := range w.Edge {
+			if edge.From.Name == id {
+				nodes[edge.To.Name].Input[slots.edge[i]] = node.Output[edge.From.LabelIndex]
+			}
 		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	runtimeNodes := map[string]wk.RuntimeNode{}
-	for name, node := range nodes {
-		runtimeNodes[name] = node.RuntimeNode
-	}
-	res := w.Analyze(w, runtimeNodes, fullscore)
-
-	// bs, _ := script.Exec("ls .").Bytes()
-	// logger.Print(string(bs))
-	return &res, nil
+	return nodes, nil
 }
 
 type sha [32]byte
@@ -144,11 +224,23 @@ func (r rtNode) Processor() processor.Processor {
 	return processors.Get(r.ProcName)
 }
 
-// sum up hash of all input files
-func (r *rtNode) calcHash() {
+// Hash of processor name, its version, params and input files along with
+// their labels, so that different processors never share outputs.
+func (r *rtNode) calcHash(proc processor.Processor) {
 	hash := sha256.New()
-	for _, path := range r.Input {
+	fmt.Fprintf(hash, "%q %q\n", r.ProcName, processor.Version(proc))
+	for _, key := range r.Params.Keys() {
+		fmt.Fprintf(hash, "%q=%q\n", key, r.Params[key])
+	}
+	inputLabel := processor.InputLabel(r.ProcName)
+	for i, path := range r.Input {
 		hashval := fileHash(path)
+		// files of a repeated input share its label
+		label := inputLabel[len(inputLabel)-1]
+		if i < len(inputLabel) {
+			label = inputLabel[i]
+		}
+		fmt.Fprintf(hash, "%d %q ", i, label)
 		hash.Write(hashval[:])
 	}
 	var b = hash.Sum(nil)
@@ -162,18 +254,74 @@ func (r *rtNode) calcHash() {
 }
 
 func (r *rtNode) inputFullfilled() bool {
-	for _, path := range r.Input {
-		if path == "" {
+	for i, path := range r.Input {
+		if path == "" && processor.InputKind(r.ProcName, i) != processor.Optional {
 			return false
 		}
 	}
 	return true
 }
 
-func runtimeNodes(node map[string]wk.Node) (res map[string]rtNode) {
-	res = map[string]rtNode{}
+// Index in Input of nodes of each connection to inputs. Inputs are at their
+// label indices, except that files of a repeated input, which is the last one,
+// are put at its index and after, in order of edges, constants and then
+// inbounds sorted by group and field.
+type inputSlots struct {
+	edge, constant []int
+	inbound        map[wk.Groupname]map[string][]int
+	// length of Input of nodes
+	size map[string]int
+}
+
+func newInputSlots(w *wk.WorkflowGraph) inputSlots {
+	res := inputSlots{inbound: map[wk.Groupname]map[string][]int{}, size: map[string]int{}}
+	for name, node := range w.Node {
+		res.size[name] = len(processor.InputLabel(node.ProcName))
+		if processor.InputKind(node.ProcName, res.size[name]-1) == processor.Repeated {
+			res.size[name]--
+		}
+	}
+	slot := func(to wk.Inbound) int {
+		if processor.InputKind(w.Node[to.Name].ProcName, to.LabelIndex) != processor.Repeated {
+			return to.LabelIndex
+		}
+		res.size[to.Name]++
+		return res.size[to.Name] - 1
+	}
+	for _, edge := range w.Edge {
+		res.edge = append(res.edge, slot(edge.To))
+	}
+	for _, constant := range w.Constant {
+		res.constant = append(res.constant, slot(constant.To))
+	}
+	groups := []wk.Groupname{}
+	for group := range w.Inbound {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	for _, group := range groups {
+		res.inbound[group] = map[string][]int{}
+		if w.Inbound[group] == nil {
+			continue
+		}
+		fields := []string{}
+		for field := range *w.Inbound[group] {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			for _, bound := range (*w.Inbound[group])[field] {
+				res.inbound[group][field] = append(res.inbound[group][field], slot(bound))
+			}
+		}
+	}
+	return res
+}
+
+func runtimeNodes(node map[string]wk.Node) (res map[string]*rtNode) {
+	res = map[string]*rtNode{}
 	for k, v := range node {
-		res[k] = rtNode{
+		res[k] = &rtNode{
 			RuntimeNode: wk.RuntimeNode{
 				Node:   v,
 				Input:  make([]string, len(processor.InputLabel(v.ProcName))),
@@ -185,37 +333,64 @@ func runtimeNodes(node map[string]wk.Node) (res map[string]rtNode) {
 	return
 }
 
-func topologicalEnum(w wk.Workflow, handler func(id string) error) error {
+// Run handler on every node in topological order. Nodes whose sources have
+// all finished are run concurrently, with at most parallel of them at a time.
+func parallelEnum(w wk.Workflow, parallel int, handler func(id string) error) error {
+	type done struct {
+		id  string
+		err error
+	}
 	indegree := map[string]int{}
 	for _, edge := range w.Edge {
 		indegree[edge.To.Name]++
 	}
+	ready := []string{}
+	for id := range w.Node {
+		if indegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	var firstErr error
+	doneCh := make(chan done)
+	running, finished := 0, 0
 	for {
-		flag := false
-		p := ""
-		for id := range w.Node {
-			if indegree[id] == 0 {
-				flag = true
-				p = id
-				break
-			}
+		for firstErr == nil && len(ready) > 0 && running < parallel {
+			id := ready[0]
+			ready = ready[1:]
+			running++
+			// logger.Printf("topo current id=%s", id)
+			go func() {
+				doneCh <- done{id: id, err: handler(id)}
+			}()
 		}
-		if !flag {
+		if running == 0 {
 			break
 		}
-		// logger.Printf("topo current id=%s", p)
-		indegree[p] = -1
-		for _, edge := range w.EdgeFrom(p) {
-			indegree[edge.To.Name]--
+		d := <-doneCh
+		running--
+		finished++
+		if d.err != nil {
+			if firstErr == nil {
+				firstErr = d.err
+			}
+			continue
 		}
-		err := handler(p)
-		if err != nil {
-			return err
+		for _, edge := range w.EdgeFrom(d.id) {
+			indegree[edge.To.Name]--
+			if indegree[edge.To.Name] == 0 {
+				ready = append(ready, edge.To.Name)
+			}
 		}
 	}
-	for id := range w.Node {
-		if indegree[id] != -1 {
-			return fmt.Errorf("invalid DAG! id=%s", id)
+	if firstErr != nil {
+		return firstErr
+	}
+	if finished != len(w.Node) {
+		for id := range w.Node {
+			if indegree[id] != 0 {
+				return fmt.Errorf("invalid DAG! id=%s", id)
+			}
 		}
 	}
 	return nil
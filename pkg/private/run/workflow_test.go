@@ -0,0 +1,145 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	wk "github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+func TestParallelEnum(t *testing.T) {
+	graph := wk.NewGraph()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		graph.Node[name] = wk.Node{}
+	}
+	// a -> c, b -> c, c -> d
+	graph.Edge = []wk.Edge{
+		{From: wk.Outbound{Name: "a"}, To: wk.Inbound{Name: "c"}},
+		{From: wk.Outbound{Name: "b"}, To: wk.Inbound{Name: "c"}},
+		{From: wk.Outbound{Name: "c"}, To: wk.Inbound{Name: "d"}},
+	}
+	w := wk.Workflow{WorkflowGraph: &graph}
+
+	var mu sync.Mutex
+	finished := map[string]bool{}
+	running, maxRunning := 0, 0
+	err := parallelEnum(w, 4, func(id string) error {
+		mu.Lock()
+		for _, edge := range w.EdgeTo(id) {
+			if !finished[edge.From.Name] {
+				t.Errorf("node %s runs before %s", id, edge.From.Name)
+			}
+		}
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		finished[id] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(finished) != 4 {
+		t.Errorf("expect 4 nodes finished, found %d", len(finished))
+	}
+	if maxRunning != 2 {
+		t.Errorf("expect a and b run concurrently, max running = %d", maxRunning)
+	}
+
+	// cycle: c -> a
+	graph.Edge = append(graph.Edge, wk.Edge{From: wk.Outbound{Name: "c"}, To: wk.Inbound{Name: "a"}})
+	err = parallelEnum(w, 4, func(id string) error { return nil })
+	if err == nil {
+		t.Errorf("expect error for invalid DAG")
+	}
+}
+
+func TestInputSlots(t *testing.T) {
+	var b wk.Builder
+	b.SetNode("make", "inputmaker", false)
+	b.SetNode("compile", "compiler", true)
+	b.AddInbound(wk.Gtests, "input", "make", "source")
+	b.AddConstant("raw", "make", "option")
+	b.AddInbound(wk.Gstatic, "script", "compile", "script")
+	b.AddInbound(wk.Gsubm, "source", "compile", "source")
+	b.AddInbound(wk.Gsubm, "header", "compile", "extra")
+	b.AddInbound(wk.Gstatic, "grader", "compile", "extra")
+	b.AddConstant("#define N 10", "compile", "extra")
+	b.AddEdge("make", "result", "compile", "extra")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots := newInputSlots(graph)
+	// make: source option generator, where generator is left empty
+	// compile: source script make.result constant static.grader submission.header
+	if slots.size["make"] != 3 || slots.size["compile"] != 6 {
+		t.Errorf("unexpected sizes %v", slots.size)
+	}
+	if slots.edge[0] != 2 || slots.constant[0] != 1 || slots.constant[1] != 3 {
+		t.Errorf("unexpected slots of edges %v and constants %v", slots.edge, slots.constant)
+	}
+	if slots.inbound[wk.Gstatic]["grader"][0] != 4 || slots.inbound[wk.Gsubm]["header"][0] != 5 ||
+		slots.inbound[wk.Gsubm]["source"][0] != 0 {
+		t.Errorf("unexpected slots of inbounds %v", slots.inbound)
+	}
+
+	node := runtimeNodes(map[string]wk.Node{"make": {ProcName: "inputmaker"}})["make"]
+	node.Input = []string{"a", "b", ""}
+	if !node.inputFullfilled() {
+		t.Errorf("optional input is not required")
+	}
+	node.Input[0] = ""
+	if node.inputFullfilled() {
+		t.Errorf("required input is empty")
+	}
+}
+
+func TestRelativeInbound(t *testing.T) {
+	var b wk.Builder
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(wk.Gsubm, "source", "check", "out")
+	b.AddInbound(wk.Gtests, "answer", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	dir := t.TempDir()
+	os.Chdir(dir)
+	os.WriteFile("a", []byte("3"), 0644)
+
+	w := wk.Workflow{WorkflowGraph: graph, Analyzer: wk.DefaultAnalyzer{}}
+	nodes, err := runNodes(context.Background(), w, t.TempDir(), map[wk.Groupname]*map[string]string{
+		wk.Gsubm:  {"source": "a"},
+		wk.Gtests: {"answer": "a"},
+	}, nil, newOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range nodes["check"].Input {
+		if input != filepath.Join(dir, "a") {
+			t.Errorf("expect absolute input path, found %q", input)
+		}
+	}
+	if res := nodes["check"].Result; res == nil || res.Code != processor.Ok {
+		t.Errorf("unexpected result %v", res)
+	}
+}
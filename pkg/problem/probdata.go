@@ -9,7 +9,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/sshwy/yaoj-core/pkg/utils"
 	"github.com/sshwy/yaoj-core/pkg/workflow"
@@ -30,6 +32,61 @@ type Result struct {
 	IsSubtask  bool
 	CalcMethod CalcMethod
 	Subtask    []SubtResult
+	// the first unaccepted title of subtasks, "Accepted" if none
+	Title     string
+	Score     float64
+	Fullscore float64
+	// total time of testcases
+	Time time.Duration
+	// maximum memory of testcases
+	Memory utils.ByteValue
+	// the judgement is cancelled, testcases not finished are "Cancelled"
+	Cancelled bool
+}
+
+// Calculate all subtasks by CalcMethod and sum them up. For Mmin, score
+// ratio of a subtask is capped by those of subtasks it depends on. Without
+// subtasks, scores of testcases are always summed up (Msum).
+func (r *Result) Calc() {
+	r.Title, r.Score, r.Time, r.Memory = "Accepted", 0, 0, 0
+	method := r.CalcMethod
+	if !r.IsSubtask {
+		method = Msum
+	}
+	done := make([]bool, len(r.Subtask))
+	var calc func(i int)
+	calc = func(i int) {
+		if done[i] {
+			return
+		}
+		done[i] = true
+		subt := &r.Subtask[i]
+		subt.Calc(method)
+		if method != Mmin {
+			return
+		}
+		for _, dep := range subt.Depend {
+			if dep < 0 || dep >= len(r.Subtask) {
+				continue
+			}
+			calc(dep)
+			if ratio := subtRatio(r.Subtask[dep]); subt.Score > ratio*subt.Fullscore {
+				subt.Score = ratio * subt.Fullscore
+			}
+		}
+	}
+	for i := range r.Subtask {
+		calc(i)
+		subt := &r.Subtask[i]
+		r.Score += subt.Score
+		r.Time += subt.Time
+		if subt.Memory > r.Memory {
+			r.Memory = subt.Memory
+		}
+		if r.Title == "Accepted" && subt.Title != "Accepted" {
+			r.Title = subt.Title
+		}
+	}
 }
 
 func (r Result) Byte() []byte {
@@ -41,8 +98,9 @@ func (r Result) Byte() []byte {
 }
 
 var briefTpl = template.Must(template.New("brief").Parse(`
+{{ .Title }} {{ .Score }}/{{ .Fullscore }}pts {{ .Time }} {{ .Memory }}{{if .Cancelled}} (cancelled){{end}}
 subtask: {{ .IsSubtask }}
-{{if .IsSubtask}}{{range .Subtask}}{{ .Subtaskid }} ({{ .Fullscore }}pts)
+{{if .IsSubtask}}{{range .Subtask}}{{ .Subtaskid }} {{ .Title }} {{ .Score }}/{{ .Fullscore }}pts
 {{range .Testcase}}{{ .Title }} {{ .Score }}pts {{ .Time }} {{ .Memory }}
 {{end}}{{end}}
 {{else}}{{range .Subtask}}{{range .Testcase}}{{ .Title }} {{ .Score }}pts {{ .Time }} {{ .Memory }}
@@ -63,6 +121,77 @@ type SubtResult struct {
 	Subtaskid string
 	Fullscore float64
 	Testcase  []workflow.Result
+	// indices of subtasks it depends on
+	Depend []int
+	// the first unaccepted title of testcases, "Accepted" if none
+	Title string
+	Score float64
+	// total time of testcases
+	Time time.Duration
+	// maximum memory of testcases
+	Memory utils.ByteValue
+}
+
+func subtRatio(res SubtResult) float64 {
+	if res.Fullscore == 0 {
+		if res.Title == "Accepted" {
+			return 1
+		}
+		return 0
+	}
+	return res.Score / res.Fullscore
+}
+
+// ratio of score of a testcase
+func scoreRatio(res workflow.Result) float64 {
+	if res.Fullscore == 0 {
+		if res.Title == "Accepted" {
+			return 1
+		}
+		return 0
+	}
+	return res.Score / res.Fullscore
+}
+
+// Calculate score, time, memory and title from testcases. For Mmin and
+// Mmax, score is Fullscore times the minimum (maximum) score ratio of
+// testcases. For Msum, score is the sum of testcases. "Skipped" testcases
+// are ignored by Mmin unless all testcases are skipped, since they are
+// skipped after a failure only.
+func (r *SubtResult) Calc(method CalcMethod) {
+	r.Title, r.Score, r.Time, r.Memory = "Accepted", 0, 0, 0
+	ran := false
+	for _, test := range r.Testcase {
+		if test.Title != "Skipped" {
+			ran = true
+		}
+	}
+	first := true
+	for _, test := range r.Testcase {
+		r.Time += test.Time
+		if test.Memory > r.Memory {
+			r.Memory = test.Memory
+		}
+		if r.Title == "Accepted" && test.Title != "Accepted" {
+			r.Title = test.Title
+		}
+		switch method {
+		case Mmin:
+			if ran && test.Title == "Skipped" {
+				continue
+			}
+			if score := scoreRatio(test) * r.Fullscore; first || score < r.Score {
+				r.Score = score
+			}
+		case Mmax:
+			if score := scoreRatio(test) * r.Fullscore; first || score > r.Score {
+				r.Score = score
+			}
+		case Msum:
+			r.Score += test.Score
+		}
+		first = false
+	}
 }
 
 // Problem data module
@@ -224,6 +353,9 @@ func (r *ProbData) SetWkflGraph(serial []byte) error {
 	if err != nil {
 		return err
 	}
+	if err := graph.Validate(); err != nil {
+		return err
+	}
 	r.workflow.WorkflowGraph = graph
 	return nil
 }
@@ -248,10 +380,16 @@ func LoadProbData(dir string) (*ProbData, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := wkgh.Validate(); err != nil {
+		return nil, err
+	}
 	prob.workflow = workflow.Workflow{
 		WorkflowGraph: wkgh,
 		Analyzer:      workflow.DefaultAnalyzer{},
 	}
+	if _, err := prob.SubtaskOrder(); err != nil {
+		return nil, err
+	}
 	return &prob, nil
 }
 
@@ -281,6 +419,60 @@ func (r *ProbData) IsSubtask() bool {
 	return len(r.Subtasks.Field) > 0 && len(r.Subtasks.Record) > 0
 }
 
+// Indices of subtasks that each subtask depends on, according to "_depend".
+// Unknown subtasks are ignored.
+func (r *ProbData) SubtaskDepend() [][]int {
+	res := make([][]int, len(r.Subtasks.Record))
+	for i, task := range r.Subtasks.Record {
+		res[i] = []int{}
+		if task["_depend"] == "" {
+			continue
+		}
+		for _, dep := range strings.Split(task["_depend"], ",") {
+			dep = strings.TrimSpace(dep)
+			for id, subt := range r.Subtasks.Record {
+				if subt["_subtaskid"] == dep {
+					res[i] = append(res[i], id)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Indices of subtasks in an order that every subtask comes after those it
+// depends on. Error if dependencies form a cycle.
+func (r *ProbData) SubtaskOrder() ([]int, error) {
+	depend := r.SubtaskDepend()
+	order := []int{}
+	// 0: unvisited, 1: visiting, 2: visited
+	state := make([]int, len(depend))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("cyclic subtask dependency: %s", r.Subtasks.Record[i]["_subtaskid"])
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, dep := range depend[i] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[i] = 2
+		order = append(order, i)
+		return nil
+	}
+	for i := range depend {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
 // get the workflow
 func (r *ProbData) Workflow() workflow.Workflow {
 	return r.workflow
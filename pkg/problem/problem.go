@@ -8,7 +8,6 @@ import (
 	"os"
 	"path"
 	"strconv"
-	"strings"
 
 	"github.com/sshwy/yaoj-core/pkg/utils"
 	"golang.org/x/text/language"
@@ -99,6 +98,7 @@ func (r *prob) DataInfo() DataInfo {
 		Subtasks:   []SubtaskInfo{},
 	}
 	if res.IsSubtask {
+		depend := r.data.SubtaskDepend()
 		for i, task := range r.data.Subtasks.Record {
 			var tests = []TestInfo{}
 			for j, test := range r.data.Tests.Record {
@@ -111,25 +111,13 @@ func (r *prob) DataInfo() DataInfo {
 				})
 			}
 
-			depend := []int{}
-			if task["_depend"] != "" {
-				deps := strings.Split(task["_depend"], ",")
-				for _, dep := range deps {
-					dep = strings.TrimSpace(dep)
-					for id, subt := range r.data.Subtasks.Record {
-						if subt["_subtaskid"] == dep {
-							depend = append(depend, id)
-						}
-					}
-				}
-			}
 			score, _ := strconv.ParseFloat(task["_score"], 64)
 			res.Subtasks = append(res.Subtasks, SubtaskInfo{
 				Id:        i,
 				Fullscore: score,
 				Field:     task,
 				Tests:     tests,
-				Depend:    depend,
+				Depend:    depend[i],
 			})
 		}
 	} else {
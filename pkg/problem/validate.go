@@ -0,0 +1,285 @@
+package problem
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+// Level of a diagnostic.
+type Level int
+
+const (
+	Info Level = iota
+	Warning
+	Error
+)
+
+func (r Level) String() string {
+	return [...]string{"info", "warning", "error"}[r]
+}
+
+// Diagnostic reported by Validate.
+type Diagnostic struct {
+	Level Level
+	// where the problem is, e.g. "tests #2 input"
+	Where string
+	Msg   string
+}
+
+func (r Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", r.Level, r.Where, r.Msg)
+}
+
+type Diagnostics []Diagnostic
+
+// Whether any diagnostic is at Error level.
+func (r Diagnostics) HasError() bool {
+	for _, d := range r {
+		if d.Level == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics at or above the level.
+func (r Diagnostics) Filter(level Level) Diagnostics {
+	res := Diagnostics{}
+	for _, d := range r {
+		if d.Level >= level {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+func (r Diagnostics) String() string {
+	lines := []string{}
+	for _, d := range r {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check problem data for mistakes which otherwise surface while judging:
+// missing files, testcases of unknown subtasks, invalid scores and
+// dependencies, and fields required by the workflow but missing in data.
+func Validate(r *ProbData) Diagnostics {
+	v := validator{prob: r}
+	v.files()
+	v.subtasks()
+	v.scores()
+	v.workflow()
+	return v.res
+}
+
+type validator struct {
+	prob *ProbData
+	res  Diagnostics
+}
+
+func (r *validator) add(level Level, where string, format string, a ...interface{}) {
+	r.res = append(r.res, Diagnostic{Level: level, Where: where, Msg: fmt.Sprintf(format, a...)})
+}
+
+// keys of m in order, excluding those starting with "_"
+func sortedFields[T any](m map[string]T) []string {
+	fields := []string{}
+	for field := range m {
+		if !strings.HasPrefix(field, "_") {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (r *validator) checkFile(where string, name string) {
+	if name == "" {
+		r.add(Error, where, "empty path")
+		return
+	}
+	info, err := os.Stat(path.Join(r.prob.Dir(), name))
+	if err != nil {
+		r.add(Error, where, "file %q not found", name)
+	} else if info.IsDir() {
+		r.add(Error, where, "%q is a directory", name)
+	}
+}
+
+// files in tables and records exist
+func (r *validator) files() {
+	for _, name := range []string{"tests", "subtasks"} {
+		tb := r.prob.Tests
+		if name == "subtasks" {
+			tb = r.prob.Subtasks
+		}
+		for i, rcd := range tb.Record {
+			for _, field := range sortedFields(tb.Field) {
+				if _, ok := rcd[field]; !ok {
+					r.add(Error, fmt.Sprintf("%s #%d %s", name, i, field), "field missing")
+				}
+			}
+			for _, field := range sortedFields(rcd) {
+				r.checkFile(fmt.Sprintf("%s #%d %s", name, i, field), rcd[field])
+			}
+		}
+	}
+	for _, field := range sortedFields(r.prob.Static) {
+		r.checkFile("static "+field, r.prob.Static[field])
+	}
+	for _, field := range sortedFields(r.prob.Statement) {
+		r.checkFile("statement "+field, r.prob.Statement[field])
+	}
+}
+
+// testcases belong to subtasks, dependencies are valid
+func (r *validator) subtasks() {
+	prob := r.prob
+	if !prob.IsSubtask() {
+		if len(prob.Tests.Record) == 0 {
+			r.add(Warning, "tests", "no testcase")
+		}
+		return
+	}
+	ids := map[string]int{}
+	for i, subtask := range prob.Subtasks.Record {
+		id := subtask["_subtaskid"]
+		where := fmt.Sprintf("subtasks #%d", i)
+		if id == "" {
+			r.add(Error, where, "_subtaskid missing")
+			continue
+		}
+		if _, ok := ids[id]; ok {
+			r.add(Error, where, "duplicated _subtaskid %q", id)
+		}
+		ids[id] = 0
+	}
+	for i, test := range prob.Tests.Record {
+		id := test["_subtaskid"]
+		if _, ok := ids[id]; !ok {
+			r.add(Error, fmt.Sprintf("tests #%d", i), "subtask %q not found", id)
+			continue
+		}
+		ids[id]++
+	}
+	for i, subtask := range prob.Subtasks.Record {
+		where := fmt.Sprintf("subtasks #%d", i)
+		if count, ok := ids[subtask["_subtaskid"]]; ok && count == 0 {
+			r.add(Warning, where, "no testcase")
+		}
+		if subtask["_depend"] == "" {
+			continue
+		}
+		for _, dep := range strings.Split(subtask["_depend"], ",") {
+			if _, ok := ids[strings.TrimSpace(dep)]; !ok {
+				r.add(Warning, where, "unknown dependency %q is ignored", strings.TrimSpace(dep))
+			}
+		}
+	}
+	if _, err := prob.SubtaskOrder(); err != nil {
+		r.add(Error, "subtasks", "%v", err)
+	}
+}
+
+// scores are valid and sum up to Fullscore
+func (r *validator) scores() {
+	prob := r.prob
+	if prob.Fullscore <= 0 {
+		r.add(Warning, "fullscore", "non-positive full score %v", prob.Fullscore)
+	}
+	sum, summed := 0.0, true
+	if prob.IsSubtask() {
+		for i, subtask := range prob.Subtasks.Record {
+			score, err := strconv.ParseFloat(subtask["_score"], 64)
+			if err != nil {
+				r.add(Error, fmt.Sprintf("subtasks #%d _score", i), "invalid score %q", subtask["_score"])
+				summed = false
+				continue
+			}
+			sum += score
+		}
+	} else {
+		for i, test := range prob.Tests.Record {
+			score, err := strconv.ParseFloat(test["_score"], 64)
+			if err != nil {
+				if test["_score"] != "average" && test["_score"] != "" {
+					r.add(Error, fmt.Sprintf("tests #%d _score", i), "invalid score %q", test["_score"])
+				}
+				score = prob.Fullscore / float64(len(prob.Tests.Record))
+			}
+			sum += score
+		}
+	}
+	if summed && len(prob.Tests.Record) > 0 && math.Abs(sum-prob.Fullscore) > 1e-6 {
+		r.add(Warning, "fullscore", "scores sum up to %v, but full score is %v", sum, prob.Fullscore)
+	}
+}
+
+// fields required by inbound of workflow are provided
+func (r *validator) workflow() {
+	prob := r.prob
+	graph := prob.Workflow().WorkflowGraph
+	if graph == nil {
+		r.add(Error, "workflow", "workflow graph missing")
+		return
+	}
+	provided := map[workflow.Groupname]map[string]bool{
+		workflow.Gtests:  prob.Tests.Field,
+		workflow.Gsubt:   prob.Subtasks.Field,
+		workflow.Gstatic: {},
+		workflow.Gsubm:   {},
+	}
+	for field := range prob.Static {
+		provided[workflow.Gstatic][field] = true
+	}
+	for field := range prob.Submission {
+		provided[workflow.Gsubm][field] = true
+	}
+	groups := []string{}
+	for group := range graph.Inbound {
+		groups = append(groups, string(group))
+	}
+	sort.Strings(groups)
+	used := map[workflow.Groupname]map[string]bool{}
+	for _, name := range groups {
+		group := workflow.Groupname(name)
+		fields, ok := provided[group]
+		if !ok {
+			if !graph.HasGroup(group) {
+				r.add(Error, "workflow", "unknown datagroup %q", group)
+			}
+			// defined by workflow, given when running
+			continue
+		}
+		used[group] = map[string]bool{}
+		if graph.Inbound[group] == nil {
+			continue
+		}
+		for _, field := range sortedFields(*graph.Inbound[group]) {
+			used[group][field] = true
+			if group == workflow.Gsubt && !prob.IsSubtask() {
+				r.add(Error, fmt.Sprintf("workflow %s %s", group, field), "subtasks are not enabled")
+			} else if group == workflow.Gsubm && len(prob.Submission) == 0 {
+				// submission format not configured
+				continue
+			} else if !fields[field] {
+				r.add(Error, fmt.Sprintf("workflow %s %s", group, field), "field missing in problem data")
+			}
+		}
+	}
+	for _, group := range []workflow.Groupname{workflow.Gtests, workflow.Gsubt, workflow.Gstatic, workflow.Gsubm} {
+		for _, field := range sortedFields(provided[group]) {
+			if !used[group][field] {
+				r.add(Info, fmt.Sprintf("%s %s", group, field), "not used by workflow")
+			}
+		}
+	}
+}
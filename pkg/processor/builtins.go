@@ -3,22 +3,64 @@ package processor
 
 // generated by scripts/genprocs
 func init() {
+	inLabel[`checker:fcmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:fcmp`]=[]string{`xmlreport`,`stderr`}
 	inLabel[`checker:hcmp`]=[]string{`out`,`ans`}
 	ouLabel[`checker:hcmp`]=[]string{`result`}
+	inLabel[`checker:icmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:icmp`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:lcmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:lcmp`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:ncmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:ncmp`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:nyesno`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:nyesno`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:rcmp4`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:rcmp4`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:rcmp6`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:rcmp6`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:rcmp9`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:rcmp9`]=[]string{`xmlreport`,`stderr`}
 	inLabel[`checker:testlib`]=[]string{`checker`,`input`,`output`,`answer`}
 	ouLabel[`checker:testlib`]=[]string{`xmlreport`,`stderr`,`judgerlog`}
-	inLabel[`compiler`]=[]string{`source`,`script`}
+	inLabel[`checker:uncmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:uncmp`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:wcmp`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:wcmp`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`checker:yesno`]=[]string{`input`,`output`,`answer`}
+	ouLabel[`checker:yesno`]=[]string{`xmlreport`,`stderr`}
+	inLabel[`compiler`]=[]string{`source`,`script`,`extra`}
 	ouLabel[`compiler`]=[]string{`result`,`log`,`judgerlog`}
+	inKind[`compiler`]=[]PortKind{Required,Required,Repeated}
 	inLabel[`compiler:auto`]=[]string{`source`}
 	ouLabel[`compiler:auto`]=[]string{`result`,`log`,`judgerlog`}
+	inLabel[`compiler:config`]=[]string{`source`,`config`}
+	ouLabel[`compiler:config`]=[]string{`result`,`log`,`judgerlog`}
 	inLabel[`compiler:testlib`]=[]string{`source`}
 	ouLabel[`compiler:testlib`]=[]string{`result`,`log`,`judgerlog`}
 	inLabel[`generator:testlib`]=[]string{`generator`,`arguments`}
 	ouLabel[`generator:testlib`]=[]string{`output`,`stderr`,`judgerlog`}
+	inKind[`generator:testlib`]=[]PortKind{Required,Optional}
+	paramKeys[`generator:testlib`]=[]string{`arguments`}
+	inParams[`generator:testlib`]=map[string][][]string{`arguments`:{[]string{`arguments`}}}
 	inLabel[`inputmaker`]=[]string{`source`,`option`,`generator`}
 	ouLabel[`inputmaker`]=[]string{`result`,`stderr`,`judgerlog`}
+	inKind[`inputmaker`]=[]PortKind{Required,Optional,Optional}
+	paramKeys[`inputmaker`]=[]string{`option`}
+	inParams[`inputmaker`]=map[string][][]string{`option`:{[]string{`option`}}}
 	inLabel[`runner:fileio`]=[]string{`executable`,`fin`,`config`}
 	ouLabel[`runner:fileio`]=[]string{`fout`,`stderr`,`judgerlog`}
+	inKind[`runner:fileio`]=[]PortKind{Required,Required,Optional}
+	paramKeys[`runner:fileio`]=[]string{`realtime`,`cputime`,`virmem`,`realmem`,`stackmem`,`outputlimit`,`fileno`,`inputfile`,`outputfile`}
+	inParams[`runner:fileio`]=map[string][][]string{`config`:{[]string{`inputfile`},[]string{`outputfile`},[]string{`realtime`,`cputime`}}}
+	inLabel[`runner:interactive`]=[]string{`executable`,`interactor`,`input`,`limit`}
+	ouLabel[`runner:interactive`]=[]string{`result`,`stderr`,`judgerlog`}
+	inKind[`runner:interactive`]=[]PortKind{Required,Required,Required,Optional}
+	paramKeys[`runner:interactive`]=[]string{`realtime`,`cputime`,`virmem`,`realmem`,`stackmem`,`outputlimit`,`fileno`}
+	inParams[`runner:interactive`]=map[string][][]string{`limit`:{[]string{`realtime`,`cputime`}}}
 	inLabel[`runner:stdio`]=[]string{`executable`,`stdin`,`limit`}
 	ouLabel[`runner:stdio`]=[]string{`stdout`,`stderr`,`judgerlog`}
+	inKind[`runner:stdio`]=[]PortKind{Required,Required,Optional}
+	paramKeys[`runner:stdio`]=[]string{`realtime`,`cputime`,`virmem`,`realmem`,`stackmem`,`outputlimit`,`fileno`}
+	inParams[`runner:stdio`]=map[string][][]string{`limit`:{[]string{`realtime`,`cputime`}}}
 }
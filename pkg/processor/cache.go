@@ -0,0 +1,33 @@
+package processor
+
+// VersionedProcessor reports a version (or fingerprint of its configuration),
+// which is part of the cache key of its outputs. Change it whenever outputs
+// of the same inputs may differ.
+type VersionedProcessor interface {
+	Processor
+	Version() string
+}
+
+// CacheableProcessor decides whether its outputs can be cached. Results of
+// runners depend on timing, which should not be cached.
+type CacheableProcessor interface {
+	Processor
+	Cacheable() bool
+}
+
+// Version of proc, "" if it does not implement VersionedProcessor.
+func Version(proc Processor) string {
+	if p, ok := proc.(VersionedProcessor); ok {
+		return p.Version()
+	}
+	return ""
+}
+
+// Whether outputs of proc can be cached. Processors not implementing
+// CacheableProcessor are cacheable.
+func Cacheable(proc Processor) bool {
+	if p, ok := proc.(CacheableProcessor); ok {
+		return p.Cacheable()
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package processor
+
+import "context"
+
+// Env describes the environment of a single execution of a processor.
+type Env struct {
+	// Working directory owned by the execution. Processors put their
+	// temporary files here instead of the working directory of the process.
+	Dir string
+	// Processors stop (killing processes they start) once it is done.
+	// nil means never.
+	Context context.Context
+}
+
+// EnvProcessor is a Processor depending on its execution environment.
+type EnvProcessor interface {
+	Processor
+	RunEnv(env Env, input []string, output []string) (result *Result)
+}
+
+// Run proc in env. Processors not implementing EnvProcessor ignore env.
+func RunWithEnv(proc Processor, env Env, input []string, output []string) *Result {
+	if p, ok := proc.(EnvProcessor); ok {
+		return p.RunEnv(env, input, output)
+	}
+	return proc.Run(input, output)
+}
@@ -1,10 +1,62 @@
 package processor
 
+import "sync"
+
+// guards the tables below, which Register writes at runtime
+var labelMu sync.RWMutex
+
 var inLabel, ouLabel map[string][]string = map[string][]string{}, map[string][]string{}
 
+// kinds of inputs of processors having optional or repeated ones
+var inKind = map[string][]PortKind{}
+
+// keys of params of processors implementing ParamProcessor
+var paramKeys = map[string][]string{}
+
+// params required instead of unconnected inputs, see ParamPortProcessor
+var inParams = map[string]map[string][][]string{}
+
 func InputLabel(name string) []string {
+	labelMu.RLock()
+	defer labelMu.RUnlock()
 	return inLabel[name]
 }
 func OutputLabel(name string) []string {
+	labelMu.RLock()
+	defer labelMu.RUnlock()
 	return ouLabel[name]
 }
+
+// Whether a processor with the name exists, either built in or registered.
+func Exists(name string) bool {
+	labelMu.RLock()
+	defer labelMu.RUnlock()
+	_, ok := inLabel[name]
+	return ok
+}
+
+// Register labels, input kinds and params of a processor added at
+// runtime, e.g. loaded as a plugin, so that it's known besides the built-in
+// ones. It's safe to call concurrently with others.
+func Register(name string, proc Processor) {
+	inlab, oulab := proc.Label()
+	kinds := InputKinds(proc)
+	labelMu.Lock()
+	defer labelMu.Unlock()
+	inLabel[name], ouLabel[name] = inlab, oulab
+	delete(paramKeys, name)
+	if p, ok := proc.(ParamProcessor); ok {
+		paramKeys[name] = p.ParamKeys()
+	}
+	delete(inParams, name)
+	if p, ok := proc.(ParamPortProcessor); ok {
+		inParams[name] = p.InputParams()
+	}
+	delete(inKind, name)
+	for _, kind := range kinds {
+		if kind != Required {
+			inKind[name] = kinds
+			break
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Params of a node in workflow graph, e.g. limitations of a runner, which
+// are given to the processor besides its input files.
+type Params map[string]string
+
+// Value of integer parameter key, and whether it is set.
+func (r Params) Int(key string) (int, bool, error) {
+	s, ok := r[key]
+	if !ok {
+		return 0, false, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, true, fmt.Errorf("param %s: %v", key, err)
+	}
+	return v, true, nil
+}
+
+// Keys of params in order.
+func (r Params) Keys() []string {
+	keys := []string{}
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParamProcessor accepts params of its node. Params it does not know should
+// be rejected, and those given as input files as well are overridden.
+type ParamProcessor interface {
+	Processor
+	// keys of params it accepts
+	ParamKeys() []string
+	RunParams(env Env, params Params, input []string, output []string) (result *Result)
+}
+
+// Check params of a node of the processor with the name, so that they are
+// rejected before running it: processors not implementing ParamProcessor
+// accept no params, and others accept those of their ParamKeys only.
+func CheckParams(name string, params Params) error {
+	labelMu.RLock()
+	keys, ok := paramKeys[name]
+	labelMu.RUnlock()
+	if !ok {
+		if len(params) > 0 {
+			return fmt.Errorf("params not supported by %s", name)
+		}
+		return nil
+	}
+	for _, key := range params.Keys() {
+		found := false
+		for _, k := range keys {
+			if k == key {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown param %q of %s", key, name)
+		}
+	}
+	return nil
+}
+
+// Run proc in env with params. Processors not implementing ParamProcessor
+// accept no params.
+func RunWithParams(proc Processor, env Env, params Params, input []string, output []string) *Result {
+	if p, ok := proc.(ParamProcessor); ok {
+		return p.RunParams(env, params, input, output)
+	}
+	if len(params) > 0 {
+		return &Result{Code: SystemError, Msg: "params not supported"}
+	}
+	return RunWithEnv(proc, env, input, output)
+}
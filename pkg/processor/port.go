@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind of an input port.
+type PortKind int
+
+const (
+	// Exactly one file is given.
+	Required PortKind = iota
+	// The input may be left unconnected, in which case its path is "".
+	Optional
+	// Any number of files are given, as the input and those after it, so
+	// only the last input can be repeated.
+	Repeated
+)
+
+func (r PortKind) String() string {
+	switch r {
+	case Required:
+		return "required"
+	case Optional:
+		return "optional"
+	case Repeated:
+		return "repeated"
+	default:
+		return "unknown"
+	}
+}
+
+// PortProcessor reports kinds of its inputs, one for each input label.
+// Inputs of other processors are all required.
+type PortProcessor interface {
+	Processor
+	InputKind() []PortKind
+}
+
+// Kinds of inputs of proc.
+func InputKinds(proc Processor) []PortKind {
+	inlab, _ := proc.Label()
+	kinds := make([]PortKind, len(inlab))
+	if p, ok := proc.(PortProcessor); ok {
+		copy(kinds, p.InputKind())
+	}
+	return kinds
+}
+
+// Kind of the index-th input of the processor with the name. Indices after the
+// last input are of a repeated one.
+func InputKind(name string, index int) PortKind {
+	labelMu.RLock()
+	kinds := inKind[name]
+	labelMu.RUnlock()
+	if n := len(kinds); n > 0 && index >= n-1 && kinds[n-1] == Repeated {
+		return Repeated
+	}
+	if index < 0 || index >= len(kinds) {
+		return Required
+	}
+	return kinds[index]
+}
+
+// ParamPortProcessor gives optional inputs by params when they are left
+// unconnected. InputParams maps labels of such inputs to params required
+// instead, each element listing alternatives, one of which must be given.
+type ParamPortProcessor interface {
+	PortProcessor
+	ParamProcessor
+	InputParams() map[string][][]string
+}
+
+// Check that params required instead of the unconnected index-th input of
+// the processor with the name are given.
+func CheckUnconnected(name string, index int, params Params) error {
+	labelMu.RLock()
+	defer labelMu.RUnlock()
+	labels := inLabel[name]
+	if index < 0 || index >= len(labels) {
+		return nil
+	}
+	return checkUnconnected(labels[index], inParams[name][labels[index]], params)
+}
+
+// Check that params required instead of unconnected inputs (whose paths are
+// "") of proc are given.
+func CheckInputs(proc ParamPortProcessor, input []string, params Params) error {
+	inlab, _ := proc.Label()
+	required := proc.InputParams()
+	for i, label := range inlab {
+		if i < len(input) && input[i] == "" {
+			if err := checkUnconnected(label, required[label], params); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkUnconnected(label string, required [][]string, params Params) error {
+	for _, alts := range required {
+		found := false
+		for _, key := range alts {
+			if _, ok := params[key]; ok {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("input %s unconnected, param %s required", label, strings.Join(alts, " or "))
+		}
+	}
+	return nil
+}
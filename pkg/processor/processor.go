@@ -2,6 +2,7 @@
 package processor
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/sshwy/yaoj-core/pkg/utils"
@@ -29,6 +30,15 @@ const (
 	ExitError
 )
 
+func (r Code) String() string {
+	names := [...]string{"Ok", "RuntimeError", "MemoryExceed", "TimeExceed", "OutputExceed",
+		"SystemError", "DangerousSyscall", "ExitError"}
+	if r < 0 || int(r) >= len(names) {
+		return fmt.Sprintf("Code(%d)", int(r))
+	}
+	return names[r]
+}
+
 // Code is required, others are optional
 type Result struct {
 	// Result status：OK/RE/MLE/...
@@ -132,6 +132,25 @@ const (
 	Lc
 )
 
+var langNames = []string{"cpp", "cpp11", "cpp14", "cpp17", "cpp20", "python2", "python3", "go", "java", "c"}
+
+func (r LangTag) String() string {
+	if r < 0 || int(r) >= len(langNames) {
+		return fmt.Sprintf("LangTag(%d)", int(r))
+	}
+	return langNames[r]
+}
+
+// Parse a LangTag from its name, e.g. "cpp17", "python3".
+func ParseLangTag(name string) (LangTag, error) {
+	for i, s := range langNames {
+		if s == name {
+			return LangTag(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown language %q", name)
+}
+
 type CtntType int
 
 const (
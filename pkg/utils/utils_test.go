@@ -11,3 +11,19 @@ func TestChecksum(t *testing.T) {
 	sum := utils.ReaderChecksum(bytes.NewReader([]byte("hello1")))
 	t.Log(sum)
 }
+
+func TestLangTag(t *testing.T) {
+	for tag := utils.Lcpp; tag <= utils.Lc; tag++ {
+		res, err := utils.ParseLangTag(tag.String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if res != tag {
+			t.Errorf("expect %v, found %v", tag, res)
+		}
+	}
+	if _, err := utils.ParseLangTag("brainfuck"); err == nil {
+		t.Errorf("expect error")
+	}
+}
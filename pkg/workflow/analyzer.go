@@ -4,10 +4,12 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	goPlugin "plugin"
+	"strconv"
+	"strings"
 
-	"github.com/k0kubun/pp/v3"
 	"github.com/sshwy/yaoj-core/pkg/processor"
 	"github.com/sshwy/yaoj-core/pkg/utils"
 	"golang.org/x/text/encoding/charmap"
@@ -40,24 +42,8 @@ type DefaultAnalyzer struct {
 }
 
 func (r DefaultAnalyzer) Analyze(w Workflow, nodes map[string]RuntimeNode, fullscore float64) Result {
-	for _, bounds := range *w.Inbound[Gsubm] {
-		for _, bound := range bounds {
-			nodes[bound.Name].Attr["dependon"] = "user"
-		}
-	}
-	for {
-		flag := false
-		for _, edge := range w.Edge {
-			if nodes[edge.From.Name].Attr["dependon"] == "user" &&
-				nodes[edge.To.Name].Attr["dependon"] != "user" {
-
-				nodes[edge.To.Name].Attr["dependon"] = "user"
-				flag = true
-			}
-		}
-		if !flag {
-			break
-		}
+	for name := range w.DependOn(Gsubm) {
+		nodes[name].Attr["dependon"] = "user"
 	}
 	res := Result{
 		ResultMeta: ResultMeta{
@@ -83,55 +69,69 @@ func (r DefaultAnalyzer) Analyze(w Workflow, nodes map[string]RuntimeNode, fulls
 		})
 
 		if node.Key {
-			res.ResultMeta.Memory += utils.ByteValue(*node.Result.Memory)
-			res.ResultMeta.Time += *node.Result.CpuTime
+			if node.Result.Memory != nil {
+				res.ResultMeta.Memory += utils.ByteValue(*node.Result.Memory)
+			}
+			if node.Result.CpuTime != nil {
+				res.ResultMeta.Time += *node.Result.CpuTime
+			}
 			res.File = append(res.File, list...)
 		}
 	}
 
-	for name, node := range nodes {
-		if node.Result == nil {
+	// failed nodes are examined in a fixed order for a stable result
+	for _, name := range w.nodeNames() {
+		node, ok := nodes[name]
+		if !ok {
 			continue
 		}
-		if node.Result.Code != processor.Ok {
+		var accepted bool
+		if node.Result == nil {
+			// a checker without result (e.g. given by a cache keeping output
+			// files only) is judged by its report, if any
+			if !isChecker(node.ProcName) || len(node.Output) == 0 {
+				continue
+			}
+			report, err := ParseTestlibReport(node.Output[0])
+			if err != nil {
+				continue
+			}
+			accepted = report.Outcome == "accepted"
+		} else {
+			accepted = node.Result.Code == processor.Ok
+			if accepted && isChecker(node.ProcName) {
+				// exit code of scored verdicts may be zero
+				if report, err := ParseTestlibReport(node.Output[0]); err == nil {
+					accepted = report.Outcome == "accepted"
+				}
+			}
+		}
+		if !accepted {
 			labels := processor.OutputLabel(node.ProcName)
 			list := []ResultFileDisplay{}
-			if !node.Key { // 如果是关键结点那么文件啥的已经被展示了
+			if !node.Key || node.Result == nil { // 如果是关键结点那么文件啥的已经被展示了
 				for i, label := range labels {
 					list = append(list, FileDisplay(node.Output[i], label, 5000))
 				}
+			}
+			if !node.Key && node.Result != nil {
 				list = append(list, ResultFileDisplay{
 					Title:   "message",
 					Content: name + ": " + node.Result.Msg,
 				})
 			}
 
-			if node.ProcName == "checker:testlib" {
-				type Result struct {
-					XMLName xml.Name `xml:"result"`
-					Outcome string   `xml:"outcome,attr"`
+			if isChecker(node.ProcName) {
+				report, err := ParseTestlibReport(node.Output[0])
+				if err != nil {
+					logger.Printf("parse report: %v", err)
 				}
-				var result Result
-				file, _ := os.Open(node.Output[0])
-				// parse xml encoded windows1251
-				d := xml.NewDecoder(file)
-				d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
-					switch charset {
-					case "windows-1251":
-						return charmap.Windows1251.NewDecoder().Reader(input), nil
-					default:
-						return nil, fmt.Errorf("unknown charset: %s", charset)
-					}
-				}
-				d.Decode(&result)
 
 				res.File = append(res.File, list...)
-				res.File = append(res.File, FileDisplay(node.Input[3], "answer", 5000))
-				if result.Outcome != "accepted" {
-					res.Title = "Wrong Answer"
-					res.Score = 0
-					pp.Print(result)
-				}
+				answer := findIndex(processor.InputLabel(node.ProcName), "answer")
+				res.File = append(res.File, FileDisplay(node.Input[answer], "answer", 5000))
+				res.Title = report.Title()
+				res.Score = fullscore * report.Ratio()
 				return res
 			}
 			if node.Attr["dependon"] == "user" {
@@ -166,4 +166,107 @@ func (r DefaultAnalyzer) Analyze(w Workflow, nodes map[string]RuntimeNode, fulls
 	return res
 }
 
+// Result of testlib checker in xml (with "-appes").
+type TestlibReport struct {
+	XMLName xml.Name `xml:"result"`
+	Outcome string   `xml:"outcome,attr"`
+	// for outcome "points" and "relative-scoring"
+	Points string `xml:"points,attr"`
+	// for outcome "partially-correct"
+	Pctype  string `xml:"pctype,attr"`
+	Message string `xml:",chardata"`
+}
+
+func ParseTestlibReport(name string) (TestlibReport, error) {
+	var report TestlibReport
+	file, err := os.Open(name)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+	// parse xml encoded windows1251
+	d := xml.NewDecoder(file)
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch charset {
+		case "windows-1251":
+			return charmap.Windows1251.NewDecoder().Reader(input), nil
+		default:
+			return nil, fmt.Errorf("unknown charset: %s", charset)
+		}
+	}
+	err = d.Decode(&report)
+	return report, err
+}
+
+// Ratio of score in [0, 1]. For "points" and "relative-scoring", points are
+// regarded as the ratio, thus scored checkers should call quitp with a value
+// in [0, 1] (e.g. 0.5 for half of the score). For "partially-correct", pctype
+// is regarded as the percentage. Values out of range are clamped as before,
+// so that checkers of problems giving points of another scale keep their
+// verdicts, while values not being a number are invalid, whose ratio is 0.
+func (r TestlibReport) Ratio() float64 {
+	ratio, ok := r.ratio()
+	if !ok {
+		return 0
+	}
+	return ratio
+}
+
+// ratio and whether it is valid
+func (r TestlibReport) ratio() (float64, bool) {
+	var ratio float64
+	switch r.Outcome {
+	case "accepted":
+		return 1, true
+	case "points", "relative-scoring":
+		var err error
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(r.Points), 64)
+		if err != nil {
+			return 0, false
+		}
+	case "partially-correct":
+		pctype, err := strconv.ParseFloat(strings.TrimSpace(r.Pctype), 64)
+		if err != nil {
+			return 0, false
+		}
+		ratio = pctype / 100
+	default:
+		return 0, true
+	}
+	if math.IsNaN(ratio) {
+		return 0, false
+	}
+	return math.Max(0, math.Min(ratio, 1)), true
+}
+
+func (r TestlibReport) Title() string {
+	switch r.Outcome {
+	case "accepted":
+		return "Accepted"
+	case "presentation-error":
+		return "Presentation Error"
+	case "fail":
+		return "Judgement Failed"
+	case "points", "relative-scoring", "partially-correct":
+		ratio, ok := r.ratio()
+		if !ok {
+			return "Judgement Failed"
+		}
+		if ratio >= 1 {
+			return "Accepted"
+		} else if ratio > 0 {
+			return "Partially Correct"
+		}
+	}
+	return "Wrong Answer"
+}
+
+// Testlib compatible checkers (e.g. checker:testlib, checker:wcmp) take
+// "answer" as input and output "xmlreport" first.
+func isChecker(procName string) bool {
+	output := processor.OutputLabel(procName)
+	return len(output) > 0 && output[0] == "xmlreport" &&
+		findIndex(processor.InputLabel(procName), "answer") != -1
+}
+
 var _ Analyzer = DefaultAnalyzer{}
@@ -10,6 +10,8 @@ import (
 type Builder struct {
 	node          map[string]Node
 	inbound, edge [][4]string
+	constant      [][3]string
+	group         []Groupname
 	err           error
 }
 
@@ -32,6 +34,21 @@ func (r *Builder) SetNode(name string, procName string, key bool) {
 	}
 }
 
+// Set param of the node, which should be set before.
+func (r *Builder) SetParam(name string, key, value string) {
+	r.tryInit()
+	node, ok := r.node[name]
+	if !ok {
+		r.setErr(&NodeError{Node: name, Err: fmt.Errorf("invalid param %s of node %s: node not found", key, name)})
+		return
+	}
+	if node.Params == nil {
+		node.Params = processor.Params{}
+	}
+	node.Params[key] = value
+	r.node[name] = node
+}
+
 func (r *Builder) AddEdge(from, frlabel, to, tolabel string) {
 	r.tryInit()
 	r.edge = append(r.edge, [4]string{from, frlabel, to, tolabel})
@@ -46,15 +63,49 @@ const (
 	Gsubm   Groupname = "submission"
 )
 
-func (r *Builder) AddInbound(group Groupname, field, to, tolabel string) {
+// Whether the group is one of the built-in groups, which are provided by
+// problems and submissions. Other groups are defined by workflow graphs.
+func (r Groupname) Builtin() bool {
+	return r == Gtests || r == Gstatic || r == Gsubm || r == Gsubt
+}
+
+// Define a datagroup besides built-in ones, e.g. "hack" for hack inputs,
+// whose data is given by the caller running the workflow.
+func (r *Builder) AddGroup(group Groupname) {
 	r.tryInit()
-	if group != Gtests && group != Gstatic && group != Gsubm && group != Gsubt {
-		r.err = fmt.Errorf("invalid group %s", group)
+	if group == "" || group.Builtin() {
+		r.setErr(&GroupError{Group: group, Err: fmt.Errorf("invalid group %q: empty or built-in", group)})
 		return
 	}
+	for _, g := range r.group {
+		if g == group {
+			r.setErr(&GroupError{Group: group, Nth: 1, Err: fmt.Errorf("invalid group %q: duplicated", group)})
+			return
+		}
+	}
+	r.group = append(r.group, group)
+}
+
+// the first error is kept
+func (r *Builder) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Add an edge from a field of the group, which is either built-in or defined
+// by AddGroup, to the input of a node.
+func (r *Builder) AddInbound(group Groupname, field, to, tolabel string) {
+	r.tryInit()
 	r.inbound = append(r.inbound, [4]string{string(group), field, to, tolabel})
 }
 
+// Add an edge from constant content to the input of a node.
+func (r *Builder) AddConstant(value, to, tolabel string) {
+	r.tryInit()
+	r.constant = append(r.constant, [3]string{value, to, tolabel})
+}
+
 func (r *Builder) WorkflowGraph() (*WorkflowGraph, error) {
 	if r.err != nil {
 		return nil, r.err
@@ -63,41 +114,64 @@ func (r *Builder) WorkflowGraph() (*WorkflowGraph, error) {
 	for name, node := range r.node {
 		graph.Node[name] = node
 	}
+	graph.Group = append(graph.Group, r.group...)
 
-	var vis = map[string]*map[string]bool{}
-	mark := func(name string, label string) {
-		if vis[name] == nil {
-			vis[name] = &map[string]bool{}
+	for _, name := range graph.nodeNames() {
+		if procName := graph.Node[name].ProcName; !processor.Exists(procName) {
+			return nil, &NodeError{Node: name, Err: fmt.Errorf("invalid node %s: unknown processor %q", name, procName)}
 		}
-		(*vis[name])[label] = true
 	}
-	get := func(name string, label string) bool {
-		if vis[name] == nil {
-			return false
+
+	// times each port is connected, see PortError
+	nin, nout := map[[2]string]int{}, map[[2]string]int{}
+	output := func(name, label, prefix string) (int, error) {
+		port := [2]string{name, label}
+		perr := func(format string, a ...interface{}) error {
+			return &PortError{Node: name, Label: label, Output: true, Nth: nout[port],
+				Err: fmt.Errorf("%s: %s", prefix, fmt.Sprintf(format, a...))}
 		}
-		return (*vis[name])[label]
+		node, ok := graph.Node[name]
+		if !ok {
+			return -1, perr("node %s not found", name)
+		}
+		index := findIndex(processor.OutputLabel(node.ProcName), label)
+		if index == -1 {
+			return -1, perr("%s has no output %s", node.ProcName, label)
+		}
+		nout[port]++
+		return index, nil
+	}
+	input := func(name, label, prefix string) (int, error) {
+		port := [2]string{name, label}
+		perr := func(format string, a ...interface{}) error {
+			return &PortError{Node: name, Label: label, Nth: nin[port],
+				Err: fmt.Errorf("%s: %s", prefix, fmt.Sprintf(format, a...))}
+		}
+		node, ok := graph.Node[name]
+		if !ok {
+			return -1, perr("node %s not found", name)
+		}
+		index := findIndex(processor.InputLabel(node.ProcName), label)
+		if index == -1 {
+			return -1, perr("%s has no input %s", node.ProcName, label)
+		}
+		if nin[port] > 0 && processor.InputKind(node.ProcName, index) != processor.Repeated {
+			return -1, perr("duplicated dest")
+		}
+		nin[port]++
+		return index, nil
 	}
 
 	for _, edge := range r.edge {
 		from, frlabel := edge[0], edge[1]
-		if _, ok := graph.Node[from]; !ok {
-			return nil, fmt.Errorf("invalid edge %v", edge)
-		}
 		to, tolabel := edge[2], edge[3]
-		if _, ok := graph.Node[to]; !ok {
-			return nil, fmt.Errorf("invalid edge %v", edge)
+		a, err := output(from, frlabel, fmt.Sprintf("invalid edge %v", edge))
+		if err != nil {
+			return nil, err
 		}
-		fout := processor.OutputLabel(graph.Node[from].ProcName)
-		a := findIndex(fout, frlabel)
-		tin := processor.InputLabel(graph.Node[to].ProcName)
-		b := findIndex(tin, tolabel)
-		if a == -1 || b == -1 {
-			return nil, fmt.Errorf("invalid edge %v", edge)
-		}
-		if get(to, tolabel) {
-			return nil, fmt.Errorf("invalid edge %v: duplicated dest", edge)
-		} else {
-			mark(to, tolabel)
+		b, err := input(to, tolabel, fmt.Sprintf("invalid edge %v", edge))
+		if err != nil {
+			return nil, err
 		}
 		graph.Edge = append(graph.Edge, Edge{
 			From: Outbound{Name: from, LabelIndex: a},
@@ -107,18 +181,12 @@ func (r *Builder) WorkflowGraph() (*WorkflowGraph, error) {
 	for _, edge := range r.inbound {
 		group, field := edge[0], edge[1]
 		to, tolabel := edge[2], edge[3]
-		if _, ok := graph.Node[to]; !ok {
-			return nil, fmt.Errorf("invalid edge %v", edge)
-		}
-		tin := processor.InputLabel(graph.Node[to].ProcName)
-		b := findIndex(tin, tolabel)
-		if b == -1 {
-			return nil, fmt.Errorf("invalid edge %v", edge)
+		if !graph.HasGroup(Groupname(group)) {
+			return nil, &GroupError{Group: Groupname(group), Nth: -1, Err: fmt.Errorf("invalid group %s", group)}
 		}
-		if get(to, tolabel) {
-			return nil, fmt.Errorf("invalid edge %v: duplicated dest", edge)
-		} else {
-			mark(to, tolabel)
+		b, err := input(to, tolabel, fmt.Sprintf("invalid edge %v", edge))
+		if err != nil {
+			return nil, err
 		}
 		if graph.Inbound[Groupname(group)] == nil {
 			graph.Inbound[Groupname(group)] = &map[string][]Inbound{}
@@ -132,12 +200,19 @@ func (r *Builder) WorkflowGraph() (*WorkflowGraph, error) {
 			LabelIndex: b,
 		})
 	}
-	for name, node := range graph.Node {
-		for _, label := range processor.InputLabel(node.ProcName) {
-			if !get(name, label) {
-				return nil, fmt.Errorf("invalid graph: unfullfilled input: %s %s", name, label)
-			}
+	for _, edge := range r.constant {
+		value, to, tolabel := edge[0], edge[1], edge[2]
+		b, err := input(to, tolabel, fmt.Sprintf("invalid constant %q", value))
+		if err != nil {
+			return nil, err
 		}
+		graph.Constant = append(graph.Constant, Constant{
+			Value: value,
+			To:    Inbound{Name: to, LabelIndex: b},
+		})
+	}
+	if err := graph.Validate(); err != nil {
+		return nil, err
 	}
 	return &graph, nil
 }
@@ -9,14 +9,24 @@ perform a single testcase's judgement.
 Each node of the graph represents a processor, with its input files and
 output files naming inbound and outbound respectively.
 
+A node may carry params, which are passed to its processor besides input files
+if the processor implements processor.ParamProcessor.
+
 A _key node_ is specially treated by analyzer which means the main process of
 submission's testing.
 
 A directed edge goes either from one of the outbounds of the source (node) to
-one of the inbounds of the destination (node), or from a field of a datagroup
-to one of the inbounds of the destination (node).
+one of the inbounds of the destination (node), from a field of a datagroup
+to one of the inbounds of the destination (node), or from a constant, which
+is written to a file when the workflow runs.
+
+Each inbound of a node is connected by exactly one edge, unless its processor
+marks it optional, which may be left unconnected, or repeated, which takes any
+number of edges (see processor.PortProcessor).
 
-Datagroups is where all data files are given from.
+Datagroups is where all data files are given from. Besides the built-in ones
+(tests, Subtask, static and submission), a graph may define its own
+datagroups, whose data is given by the caller running the workflow.
 
 Analyzer
 
@@ -27,5 +37,13 @@ Builder
 
 Builder provides a convenient way to create a workflow graph.
 
+A workflow graph can also be defined in YAML, referring to inbounds and
+outbounds by their labels instead of indices. See LoadYAML.
+
+Rendering
+
+A workflow graph can be rendered in Graphviz DOT language or as a Mermaid
+flowchart for debugging, optionally overlaid with results of a run.
+
 */
 package workflow
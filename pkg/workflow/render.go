@@ -0,0 +1,241 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+)
+
+// Results of nodes overlaid on a rendered graph, keyed by node name. Nodes
+// absent from the map are not coloured, and a nil result (e.g. a skipped node)
+// is rendered as not run.
+type Overlay map[string]*processor.Result
+
+// fill colour of a node
+func (r Overlay) color(name string) string {
+	result, ok := r[name]
+	if !ok {
+		return ""
+	}
+	if result == nil {
+		return "lightgrey"
+	}
+	switch result.Code {
+	case processor.Ok:
+		return "palegreen"
+	case processor.SystemError:
+		return "orange"
+	default:
+		return "lightcoral"
+	}
+}
+
+func (r Overlay) tooltip(name string) string {
+	result, ok := r[name]
+	if !ok {
+		return ""
+	}
+	if result == nil {
+		return "not run"
+	}
+	if result.Msg == "" {
+		return result.Code.String()
+	}
+	return result.Code.String() + ": " + result.Msg
+}
+
+func (r *WorkflowGraph) nodeNames() []string {
+	names := []string{}
+	for name := range r.Node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *WorkflowGraph) groupNames() []Groupname {
+	groups := []Groupname{}
+	for group := range r.Inbound {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	return groups
+}
+
+func (r *WorkflowGraph) fieldNames(group Groupname) []string {
+	fields := []string{}
+	if r.Inbound[group] == nil {
+		return fields
+	}
+	for field := range *r.Inbound[group] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// label of the port, or its index if out of range
+func portLabel(labels []string, index int) string {
+	if index >= 0 && index < len(labels) {
+		return labels[index]
+	}
+	return fmt.Sprint(index)
+}
+
+// content of constant to display
+func constantLabel(value string) string {
+	if runes := []rune(value); len(runes) > 32 {
+		return string(runes[:29]) + "..."
+	}
+	return value
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape special characters of record labels as well
+var dotRecordEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`,
+	`{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`, ` `, `\ `)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// Render the graph in Graphviz DOT language. Each node is a record whose
+// left and right ports are its inputs and outputs, key nodes are in bold, and
+// fields of datagroups are clustered by group. Nodes are coloured by result
+// code if overlay is not nil.
+func (r *WorkflowGraph) DOT(overlay Overlay) string {
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=record, fontname=\"monospace\"];\n")
+
+	for _, name := range r.nodeNames() {
+		node := r.Node[name]
+		ports := func(prefix string, labels []string) string {
+			list := []string{}
+			for i, label := range labels {
+				list = append(list, fmt.Sprintf("<%s%d> %s", prefix, i, dotRecordEscaper.Replace(label)))
+			}
+			return strings.Join(list, "|")
+		}
+		title := dotRecordEscaper.Replace(name) + `\n` + dotRecordEscaper.Replace(node.ProcName)
+		if node.Key {
+			title += `\n(key)`
+		}
+		attrs := []string{fmt.Sprintf(`label="{{%s}|%s|{%s}}"`,
+			ports("i", processor.InputLabel(node.ProcName)), title,
+			ports("o", processor.OutputLabel(node.ProcName)))}
+		style := []string{}
+		if node.Key {
+			style = append(style, "bold")
+			attrs = append(attrs, "penwidth=2")
+		}
+		if color := overlay.color(name); color != "" {
+			style = append(style, "filled")
+			attrs = append(attrs, "fillcolor="+dotQuote(color), "tooltip="+dotQuote(overlay.tooltip(name)))
+		}
+		if len(style) > 0 {
+			attrs = append(attrs, "style="+dotQuote(strings.Join(style, ",")))
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+	}
+
+	for _, group := range r.groupNames() {
+		fmt.Fprintf(&b, "\tsubgraph %s {\n", dotQuote("cluster_"+string(group)))
+		fmt.Fprintf(&b, "\t\tlabel=%s;\n\t\tstyle=dashed;\n", dotQuote(string(group)))
+		for _, field := range r.fieldNames(group) {
+			fmt.Fprintf(&b, "\t\t%s [shape=box, label=%s];\n", dotQuote(string(group)+"."+field), dotQuote(field))
+		}
+		b.WriteString("\t}\n")
+	}
+
+	for _, group := range r.groupNames() {
+		for _, field := range r.fieldNames(group) {
+			for _, bound := range (*r.Inbound[group])[field] {
+				fmt.Fprintf(&b, "\t%s -> %s:i%d;\n", dotQuote(string(group)+"."+field), dotQuote(bound.Name), bound.LabelIndex)
+			}
+		}
+	}
+	for i, constant := range r.Constant {
+		fmt.Fprintf(&b, "\t%s [shape=note, label=%s];\n", dotQuote(fmt.Sprint("constant.", i)), dotQuote(constantLabel(constant.Value)))
+		fmt.Fprintf(&b, "\t%s -> %s:i%d;\n", dotQuote(fmt.Sprint("constant.", i)), dotQuote(constant.To.Name), constant.To.LabelIndex)
+	}
+	for _, edge := range r.Edge {
+		fmt.Fprintf(&b, "\t%s:o%d -> %s:i%d;\n", dotQuote(edge.From.Name), edge.From.LabelIndex,
+			dotQuote(edge.To.Name), edge.To.LabelIndex)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "\n", "<br/>")
+
+func mermaidQuote(s string) string {
+	return `"` + mermaidEscaper.Replace(s) + `"`
+}
+
+// Render the graph as a Mermaid flowchart. Since Mermaid has no ports, edges
+// are labelled with "output -> input" instead. Fields of datagroups are
+// put in subgraphs. Nodes are coloured by result code if overlay is not nil.
+func (r *WorkflowGraph) Mermaid(overlay Overlay) string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	// node names are not valid mermaid ids in general
+	id := map[string]string{}
+	classes := map[string][]string{}
+	for i, name := range r.nodeNames() {
+		node := r.Node[name]
+		id[name] = fmt.Sprintf("n%d", i)
+		title := name + "\n" + node.ProcName
+		if node.Key {
+			title += "\n(key)"
+			fmt.Fprintf(&b, "\t%s[[%s]]\n", id[name], mermaidQuote(title))
+		} else {
+			fmt.Fprintf(&b, "\t%s[%s]\n", id[name], mermaidQuote(title))
+		}
+		if color := overlay.color(name); color != "" {
+			classes[color] = append(classes[color], id[name])
+		}
+	}
+
+	for i, group := range r.groupNames() {
+		fmt.Fprintf(&b, "\tsubgraph g%d [%s]\n", i, mermaidQuote(string(group)))
+		for j, field := range r.fieldNames(group) {
+			fmt.Fprintf(&b, "\t\tg%df%d[/%s/]\n", i, j, mermaidQuote(field))
+		}
+		b.WriteString("\tend\n")
+	}
+
+	for i, group := range r.groupNames() {
+		for j, field := range r.fieldNames(group) {
+			for _, bound := range (*r.Inbound[group])[field] {
+				label := portLabel(processor.InputLabel(r.Node[bound.Name].ProcName), bound.LabelIndex)
+				fmt.Fprintf(&b, "\tg%df%d -- %s --> %s\n", i, j, mermaidQuote(label), id[bound.Name])
+			}
+		}
+	}
+	for i, constant := range r.Constant {
+		label := portLabel(processor.InputLabel(r.Node[constant.To.Name].ProcName), constant.To.LabelIndex)
+		fmt.Fprintf(&b, "\tc%d>%s] -- %s --> %s\n", i, mermaidQuote(constantLabel(constant.Value)), mermaidQuote(label), id[constant.To.Name])
+	}
+	for _, edge := range r.Edge {
+		label := portLabel(processor.OutputLabel(r.Node[edge.From.Name].ProcName), edge.From.LabelIndex) +
+			" -> " + portLabel(processor.InputLabel(r.Node[edge.To.Name].ProcName), edge.To.LabelIndex)
+		fmt.Fprintf(&b, "\t%s -- %s --> %s\n", id[edge.From.Name], mermaidQuote(label), id[edge.To.Name])
+	}
+
+	colors := []string{}
+	for color := range classes {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+	for _, color := range colors {
+		fmt.Fprintf(&b, "\tclassDef %s fill:%s\n", color, color)
+		fmt.Fprintf(&b, "\tclass %s %s\n", strings.Join(classes[color], ","), color)
+	}
+	return b.String()
+}
@@ -2,6 +2,8 @@ package workflow
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"os"
 	"strings"
 	"time"
@@ -28,11 +30,20 @@ type Edge struct {
 	To   Inbound
 }
 
+// Constant content given to an input of a node, which is written to a file
+// when the workflow runs.
+type Constant struct {
+	Value string
+	To    Inbound
+}
+
 type Node struct {
 	// processor name
 	ProcName string
 	// key node is attached importance by Analyzer
 	Key bool
+	// params given to the processor, see processor.ParamProcessor
+	Params processor.Params `json:",omitempty"`
 }
 
 type WorkflowGraph struct {
@@ -42,6 +53,23 @@ type WorkflowGraph struct {
 	// inbound consists a series of data group.
 	// Inbound: map[datagroup_name]*map[field]Bound
 	Inbound map[Groupname]*map[string][]Inbound
+	// inputs given by constants instead of datagroups
+	Constant []Constant `json:",omitempty"`
+	// datagroups defined besides built-in ones
+	Group []Groupname `json:",omitempty"`
+}
+
+// Whether the group is built-in or defined by the graph.
+func (r *WorkflowGraph) HasGroup(group Groupname) bool {
+	if group.Builtin() {
+		return true
+	}
+	for _, g := range r.Group {
+		if g == group {
+			return true
+		}
+	}
+	return false
 }
 
 // Generate json content
@@ -75,6 +103,218 @@ func (r *WorkflowGraph) EdgeTo(name string) []Edge {
 	return res
 }
 
+// Names of nodes depending (directly or indirectly) on the datagroup.
+func (r *WorkflowGraph) DependOn(group Groupname) map[string]bool {
+	res := map[string]bool{}
+	if r.Inbound[group] == nil {
+		return res
+	}
+	for _, bounds := range *r.Inbound[group] {
+		for _, bound := range bounds {
+			res[bound.Name] = true
+		}
+	}
+	for {
+		flag := false
+		for _, edge := range r.Edge {
+			if res[edge.From.Name] && !res[edge.To.Name] {
+				res[edge.To.Name] = true
+				flag = true
+			}
+		}
+		if !flag {
+			break
+		}
+	}
+	return res
+}
+
+// Check that processors of nodes exist and accept their params, edges are
+// between existing nodes and labels, each input of a node is fulfilled by
+// exactly one edge (at most one for optional inputs, whose params are given
+// instead if unconnected, and any number for repeated ones), and the graph
+// is acyclic.
+func (r *WorkflowGraph) Validate() error {
+	for name, node := range r.Node {
+		if !processor.Exists(node.ProcName) {
+			return &NodeError{Node: name, Err: fmt.Errorf("invalid node %s: unknown processor %q", name, node.ProcName)}
+		}
+		if err := processor.CheckParams(node.ProcName, node.Params); err != nil {
+			return &NodeError{Node: name, Err: fmt.Errorf("invalid node %s: %v", name, err)}
+		}
+	}
+	var vis = map[Inbound]bool{}
+	checkInbound := func(to Inbound) error {
+		node, ok := r.Node[to.Name]
+		if !ok {
+			return fmt.Errorf("node %s not found", to.Name)
+		}
+		if to.LabelIndex < 0 || to.LabelIndex >= len(processor.InputLabel(node.ProcName)) {
+			return fmt.Errorf("input index %d out of range", to.LabelIndex)
+		}
+		if vis[to] && processor.InputKind(node.ProcName, to.LabelIndex) != processor.Repeated {
+			return fmt.Errorf("duplicated dest")
+		}
+		vis[to] = true
+		return nil
+	}
+	for _, edge := range r.Edge {
+		node, ok := r.Node[edge.From.Name]
+		if !ok {
+			return fmt.Errorf("invalid edge %v: node %s not found", edge, edge.From.Name)
+		}
+		if edge.From.LabelIndex < 0 || edge.From.LabelIndex >= len(processor.OutputLabel(node.ProcName)) {
+			return fmt.Errorf("invalid edge %v: output index %d out of range", edge, edge.From.LabelIndex)
+		}
+		if err := checkInbound(edge.To); err != nil {
+			return fmt.Errorf("invalid edge %v: %v", edge, err)
+		}
+	}
+	for _, constant := range r.Constant {
+		if err := checkInbound(constant.To); err != nil {
+			return fmt.Errorf("invalid constant %q: %v", constant.Value, err)
+		}
+	}
+	defined := map[Groupname]bool{}
+	for _, group := range r.Group {
+		if group == "" || group.Builtin() || defined[group] {
+			return fmt.Errorf("invalid group definition %q", group)
+		}
+		defined[group] = true
+	}
+	for group, fields := range r.Inbound {
+		if !r.HasGroup(group) {
+			return fmt.Errorf("invalid group %s", group)
+		}
+		if fields == nil {
+			continue
+		}
+		for field, bounds := range *fields {
+			for _, bound := range bounds {
+				if err := checkInbound(bound); err != nil {
+					return fmt.Errorf("invalid inbound %s %s %v: %v", group, field, bound, err)
+				}
+			}
+		}
+	}
+	for name, node := range r.Node {
+		for i, label := range processor.InputLabel(node.ProcName) {
+			if vis[Inbound{Name: name, LabelIndex: i}] {
+				continue
+			}
+			if processor.InputKind(node.ProcName, i) == processor.Required {
+				return &NodeError{Node: name, Err: fmt.Errorf("invalid graph: unfullfilled input: %s %s", name, label)}
+			}
+			if err := processor.CheckUnconnected(node.ProcName, i, node.Params); err != nil {
+				return &NodeError{Node: name, Err: fmt.Errorf("invalid node %s: %v", name, err)}
+			}
+		}
+	}
+
+	// topological sort
+	degree := map[string]int{}
+	for _, edge := range r.Edge {
+		degree[edge.To.Name]++
+	}
+	queue := []string{}
+	for name := range r.Node {
+		if degree[name] == 0 {
+			queue = append(queue, name)
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		for _, edge := range r.EdgeFrom(queue[i]) {
+			degree[edge.To.Name]--
+			if degree[edge.To.Name] == 0 {
+				queue = append(queue, edge.To.Name)
+			}
+		}
+	}
+	if len(queue) != len(r.Node) {
+		// nodes left are in or after a cycle, report the first one in a cycle
+		name := ""
+		for _, n := range r.nodeNames() {
+			if degree[n] > 0 && r.inCycle(n, degree) {
+				name = n
+				break
+			}
+		}
+		return &NodeError{Node: name, Err: fmt.Errorf("invalid graph: cycle detected at node %s", name)}
+	}
+	return nil
+}
+
+// Whether name reaches itself through nodes left by topological sort.
+func (r *WorkflowGraph) inCycle(name string, degree map[string]int) bool {
+	vis := map[string]bool{}
+	stack := []string{name}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, edge := range r.EdgeFrom(cur) {
+			to := edge.To.Name
+			if to == name {
+				return true
+			}
+			if degree[to] > 0 && !vis[to] {
+				vis[to] = true
+				stack = append(stack, to)
+			}
+		}
+	}
+	return false
+}
+
+// Error of a graph concerning a node.
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (r *NodeError) Error() string {
+	return r.Err.Error()
+}
+
+func (r *NodeError) Unwrap() error {
+	return r.Err
+}
+
+// Error of a graph concerning a port of a node, i.e. its input, or output if
+// Output. Ports connected several times are told apart by Nth, the number of
+// times it's connected before, counting edges, inbound edges and constants
+// in order of adding to Builder.
+type PortError struct {
+	Node, Label string
+	Output      bool
+	Nth         int
+	Err         error
+}
+
+func (r *PortError) Error() string {
+	return r.Err.Error()
+}
+
+func (r *PortError) Unwrap() error {
+	return r.Err
+}
+
+// Error of a graph concerning a datagroup, which is defined by AddGroup for
+// the (Nth+1)-th time, or referred to by inbound edges without being defined
+// if Nth is -1.
+type GroupError struct {
+	Group Groupname
+	Nth   int
+	Err   error
+}
+
+func (r *GroupError) Error() string {
+	return r.Err.Error()
+}
+
+func (r *GroupError) Unwrap() error {
+	return r.Err
+}
+
 // Load graph from serialized data (json)
 func Load(serial []byte) (*WorkflowGraph, error) {
 	var graph WorkflowGraph
@@ -174,3 +414,5 @@ type RuntimeNode struct {
 	// whether its output is determined by problem-wide things only
 	Attr map[string]string
 }
+
+var logger = log.New(os.Stderr, "[workflow] ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix)
@@ -1,9 +1,15 @@
 package workflow_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/k0kubun/pp/v3"
+	"github.com/sshwy/yaoj-core/pkg/processor"
 	"github.com/sshwy/yaoj-core/pkg/workflow"
 )
 
@@ -16,3 +22,363 @@ func TestLoadAnalyzer(t *testing.T) {
 	}
 	t.Log(pp.Sprint(a))
 }
+
+func TestTestlibReport(t *testing.T) {
+	dir := t.TempDir()
+	header := `<?xml version="1.0" encoding="windows-1251"?>`
+	for i, c := range []struct {
+		content string
+		ratio   float64
+		title   string
+	}{
+		{`<result outcome = "accepted">ok</result>`, 1, "Accepted"},
+		{`<result outcome = "wrong-answer">1st words differ</result>`, 0, "Wrong Answer"},
+		{`<result outcome = "presentation-error">Extra information</result>`, 0, "Presentation Error"},
+		{`<result outcome = "fail">answer is invalid</result>`, 0, "Judgement Failed"},
+		{`<result outcome = "points" points = "0.25">ja=1 pa=2</result>`, 0.25, "Partially Correct"},
+		{`<result outcome = "relative-scoring" points = "1">full</result>`, 1, "Accepted"},
+		{`<result outcome = "points" points = "2">ja=1 pa=3</result>`, 1, "Accepted"},
+		{`<result outcome = "points" points = "-1">ja=1 pa=0</result>`, 0, "Wrong Answer"},
+		{`<result outcome = "points" points = "nan">ja=1 pa=0</result>`, 0, "Judgement Failed"},
+		{`<result outcome = "points" points = "x">invalid</result>`, 0, "Judgement Failed"},
+		{`<result outcome = "partially-correct" pctype = "40">half</result>`, 0.4, "Partially Correct"},
+		{`<result outcome = "points" points = "0">zero</result>`, 0, "Wrong Answer"},
+	} {
+		name := path.Join(dir, fmt.Sprint(i, ".xml"))
+		if err := os.WriteFile(name, []byte(header+c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		report, err := workflow.ParseTestlibReport(name)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if report.Ratio() != c.ratio || report.Title() != c.title {
+			t.Errorf("#%d: expect (%v, %s), found (%v, %s)", i, c.ratio, c.title, report.Ratio(), report.Title())
+		}
+	}
+}
+
+func TestAnalyzeReport(t *testing.T) {
+	dir := t.TempDir()
+	var b workflow.Builder
+	b.SetNode("check", "checker:wcmp", true)
+	b.AddInbound(workflow.Gtests, "input", "check", "input")
+	b.AddInbound(workflow.Gsubm, "source", "check", "output")
+	b.AddInbound(workflow.Gtests, "answer", "check", "answer")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := workflow.Workflow{WorkflowGraph: graph, Analyzer: workflow.DefaultAnalyzer{}}
+	report := path.Join(dir, "report")
+	os.WriteFile(report, []byte(`<result outcome = "points" points = "0.5">half</result>`), 0644)
+	check := workflow.RuntimeNode{
+		Node:   graph.Node["check"],
+		Input:  []string{path.Join(dir, "in"), path.Join(dir, "out"), path.Join(dir, "ans")},
+		Output: []string{report, path.Join(dir, "stderr")},
+		Attr:   map[string]string{},
+	}
+	// exit code of the checker is zero, or its result is unknown
+	for _, result := range []*processor.Result{{Code: processor.Ok}, nil} {
+		check.Result = result
+		res := w.Analyze(w, map[string]workflow.RuntimeNode{"check": check}, 100)
+		if res.Score != 50 || res.Title != "Partially Correct" {
+			t.Errorf("result %v: expect (50, Partially Correct), found (%v, %s)", result, res.Score, res.Title)
+		}
+	}
+}
+
+func TestDependOn(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("compile", "compiler:auto", false)
+	b.SetNode("run", "runner:stdio", true)
+	b.SetNode("gen", "generator:testlib", false)
+	b.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	b.AddInbound(workflow.Gstatic, "generator", "gen", "generator")
+	b.AddInbound(workflow.Gtests, "arguments", "gen", "arguments")
+	b.AddInbound(workflow.Gstatic, "limit", "run", "limit")
+	b.AddEdge("compile", "result", "run", "executable")
+	b.AddEdge("gen", "output", "run", "stdin")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := graph.DependOn(workflow.Gsubm)
+	if len(nodes) != 2 || !nodes["compile"] || !nodes["run"] {
+		t.Errorf("unexpected nodes depending on submission: %v", nodes)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("a", "checker:hcmp", true)
+	b.SetNode("b", "checker:hcmp", false)
+	b.AddInbound(workflow.Gsubm, "source", "a", "out")
+	b.AddInbound(workflow.Gtests, "answer", "a", "ans")
+	b.AddEdge("a", "result", "b", "out")
+	b.AddInbound(workflow.Gtests, "answer", "b", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, serial := range map[string]string{
+		"UnknownProcessor": `{"Node":{"a":{"ProcName":"checker:none"}}}`,
+		"ParamsNotSupported": `{"Node":{"a":{"ProcName":"checker:hcmp","Params":{"x":"1"}}},
+			"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":0},{"Name":"a","LabelIndex":1}]}}}`,
+		"UnknownParam": `{"Node":{"a":{"ProcName":"inputmaker","Params":{"x":"1"}}},
+			"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":0},{"Name":"a","LabelIndex":1}]}}}`,
+		"LabelIndex":     `{"Node":{"a":{"ProcName":"checker:hcmp"}},"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":2}]}}}`,
+		"DuplicatedDest": `{"Node":{"a":{"ProcName":"checker:hcmp"}},"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":0},{"Name":"a","LabelIndex":0}]}}}`,
+		"Unfulfilled":    `{"Node":{"a":{"ProcName":"checker:hcmp"}},"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":0}]}}}`,
+		"InvalidGroup":   `{"Node":{},"Inbound":{"unknown":{}}}`,
+		"LimitMissing": `{"Node":{"a":{"ProcName":"runner:stdio","Params":{"fileno":"10"}}},
+			"Inbound":{"tests":{"input":[{"Name":"a","LabelIndex":0},{"Name":"a","LabelIndex":1}]}}}`,
+		"Cycle": `{"Node":{"a":{"ProcName":"checker:hcmp"},"b":{"ProcName":"checker:hcmp"}},
+			"Edge":[{"From":{"Name":"a","LabelIndex":0},"To":{"Name":"b","LabelIndex":0}},
+				{"From":{"Name":"b","LabelIndex":0},"To":{"Name":"a","LabelIndex":0}}],
+			"Inbound":{"tests":{"answer":[{"Name":"a","LabelIndex":1},{"Name":"b","LabelIndex":1}]}}}`,
+	} {
+		g, err := workflow.Load([]byte(serial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = g.Validate()
+		t.Logf("%s: %v", name, err)
+		if err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+
+	g, err := workflow.Load(graph.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// limitations are given by params instead of "limit"
+	var b2 workflow.Builder
+	b2.SetNode("run", "runner:stdio", true)
+	b2.SetParam("run", "realtime", "1000")
+	b2.AddInbound(workflow.Gsubm, "source", "run", "executable")
+	b2.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	if _, err := b2.WorkflowGraph(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("compile", "compiler:auto", false)
+	b.SetNode("run", "runner:stdio", true)
+	b.SetNode("check", "checker:hcmp", false)
+	b.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	b.AddInbound(workflow.Gtests, "input", "run", "stdin")
+	b.AddInbound(workflow.Gtests, "answer", "check", "ans")
+	b.AddInbound(workflow.Gstatic, "limit", "run", "limit")
+	b.AddEdge("compile", "result", "run", "executable")
+	b.AddEdge("run", "stdout", "check", "out")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := workflow.Overlay{
+		"compile": &processor.Result{Code: processor.Ok},
+		"run":     &processor.Result{Code: processor.TimeExceed, Msg: "time limit exceeded"},
+		"check":   nil,
+	}
+
+	dot := graph.DOT(overlay)
+	t.Log(dot)
+	for _, s := range []string{
+		`"compile":o0 -> "run":i0;`,
+		`"tests.answer" -> "check":i1;`,
+		`subgraph "cluster_static"`,
+		`fillcolor="lightcoral"`,
+		`tooltip="TimeExceed: time limit exceeded"`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("expect %q in DOT", s)
+		}
+	}
+
+	mermaid := graph.Mermaid(nil)
+	t.Log(mermaid)
+	for _, s := range []string{
+		`n2[["run<br/>runner:stdio<br/>(key)"]]`,
+		`n1 -- "result -> executable" --> n2`,
+		`subgraph g2 ["tests"]`,
+	} {
+		if !strings.Contains(mermaid, s) {
+			t.Errorf("expect %q in Mermaid", s)
+		}
+	}
+	if strings.Contains(mermaid, "classDef") {
+		t.Errorf("unexpected overlay")
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	graph, err := workflow.LoadYAML([]byte(`nodes:
+  compile:
+    processor: compiler:auto
+  run:
+    processor: runner:stdio
+    key: true
+    params:
+      realtime: 1000
+      fileno: 10
+  check:
+    processor: checker:hcmp
+edges:
+  - from: compile.result
+    to: run.executable
+  - from: run.stdout
+    to: check.out
+inbound:
+  - from: submission.source
+    to: compile.source
+  - from: tests.input
+    to: run.stdin
+  - from: tests.answer
+    to: check.ans
+constants:
+  - value: "1000 1000 0 0 0 0 10"
+    to: run.limit
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := graph.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(serial))
+	graph2, err := workflow.LoadYAML(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(graph.Serialize()) != string(graph2.Serialize()) {
+		t.Errorf("round trip failed:\n%s\n%s", graph.Serialize(), graph2.Serialize())
+	}
+	if len(graph.Constant) != 1 || graph.Constant[0].To.Name != "run" || graph.Constant[0].To.LabelIndex != 2 {
+		t.Errorf("unexpected constants %v", graph.Constant)
+	}
+	if graph.Node["run"].Params["realtime"] != "1000" {
+		t.Errorf("unexpected params %v", graph.Node["run"].Params)
+	}
+	if g, err := workflow.Load(graph.Serialize()); err != nil || g.Node["run"].Params["fileno"] != "10" {
+		t.Errorf("params not serialized: %v", err)
+	}
+
+	for _, c := range []struct {
+		serial       string
+		line, column int
+	}{
+		{"nodes:\n  run:\n    processor: runner:none\n", 3, 16},
+		{"nodes:\n  check:\n    processor: checker:hcmp\ninbound:\n  - from: tests.answer\n    to: check.answer\n", 6, 9},
+		{"nodes:\n  check:\n    processor: checker:hcmp\ninbound:\n  - from: tests.answer\n    to: check.ans\n", 2, 3},
+		{"nodes:\n  check:\n    processor: checker:hcmp\n    unknown: 1\n", 4, 0},
+		{"nodes:\n  check: checker:hcmp\n  run: [\n", 3, 0},
+		{"nodes:\n  check:\n    processor: [checker:hcmp]\n", 3, 16},
+		{"nodes:\n  check:\n    processor: checker:hcmp\ninbound:\n  - from: unknown.answer\n    to: check.ans\n", 5, 11},
+		{"nodes:\n  check:\n    processor: checker:hcmp\n    params:\n      x: 1\ninbound:\n  - from: tests.answer\n    to: check.ans\n  - from: tests.output\n    to: check.out\n", 2, 3},
+		{"nodes:\n  b:\n    processor: checker:hcmp\n  a:\n    processor: checker:hcmp\nedges:\n  - from: a.result\n    to: b.out\n  - from: b.result\n    to: a.out\ninbound:\n  - from: tests.answer\n    to: a.ans\n  - from: tests.answer\n    to: b.ans\n", 4, 3},
+		{"nodes:\n  check:\n    processor: checker:hcmp\ninbound:\n  - from: tests.answer\n    to: check.ans\n  - from: tests.output\n    to: check.ans\n", 8, 9},
+		{"nodes:\n  a:\n    processor: checker:hcmp\n  b:\n    processor: checker:hcmp\nedges:\n  - from: a.output\n    to: b.out\n", 7, 11},
+		{"nodes:\n  a:\n    processor: checker:hcmp\nconstants:\n  - value: x\n    to: b.out\n", 6, 9},
+		{"groups: [hack, hack]\nnodes: {}\n", 1, 16},
+	} {
+		_, err := workflow.LoadYAML([]byte(c.serial))
+		t.Log(err)
+		var yerr *workflow.YAMLError
+		if !errors.As(err, &yerr) {
+			t.Errorf("expect YAMLError, found %v", err)
+			continue
+		}
+		if yerr.Line != c.line || yerr.Column != c.column {
+			t.Errorf("expect error at %d:%d, found %v", c.line, c.column, err)
+		}
+	}
+}
+
+func TestBuilderError(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddInbound(workflow.Gtests, "answer", "check", "ans")
+	b.AddConstant("3", "check", "ans")
+	_, err := b.WorkflowGraph()
+	var perr *workflow.PortError
+	if !errors.As(err, &perr) || perr.Node != "check" || perr.Label != "ans" || perr.Output || perr.Nth != 1 {
+		t.Errorf("expect PortError of the second dest check.ans, found %#v", err)
+	}
+
+	var b2 workflow.Builder
+	b2.SetNode("check", "checker:hcmp", true)
+	b2.AddInbound("hack", "out", "check", "out")
+	_, err = b2.WorkflowGraph()
+	var gerr *workflow.GroupError
+	if !errors.As(err, &gerr) || gerr.Group != "hack" || gerr.Nth != -1 {
+		t.Errorf("expect GroupError of undefined group hack, found %#v", err)
+	}
+}
+
+func TestPorts(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("make", "inputmaker", false)
+	b.SetNode("compile", "compiler", true)
+	b.AddInbound(workflow.Gtests, "input", "make", "source")
+	b.AddConstant("raw", "make", "option")
+	b.AddInbound(workflow.Gsubm, "source", "compile", "source")
+	b.AddInbound(workflow.Gstatic, "script", "compile", "script")
+	b.AddInbound(workflow.Gsubm, "header", "compile", "extra")
+	b.AddInbound(workflow.Gstatic, "grader", "compile", "extra")
+	b.AddEdge("make", "result", "compile", "extra")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := workflow.Load(graph.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Validate(); err != nil {
+		t.Error(err)
+	}
+	serial, err := graph.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := workflow.LoadYAML(serial); err != nil {
+		t.Errorf("load YAML: %v\n%s", err, serial)
+	}
+
+	// inputs not repeated take at most one edge
+	b.AddConstant("raw", "make", "generator")
+	b.AddInbound(workflow.Gstatic, "generator", "make", "generator")
+	if _, err := b.WorkflowGraph(); err == nil {
+		t.Errorf("expect error for duplicated dest")
+	}
+	_, err = workflow.LoadYAML([]byte(`nodes:
+  make:
+    processor: inputmaker
+inbound:
+  - from: tests.input
+    to: make.source
+  - from: static.a
+    to: make.generator
+  - from: static.b
+    to: make.generator
+constants:
+  - value: raw
+    to: make.option
+`))
+	var yerr *workflow.YAMLError
+	if !errors.As(err, &yerr) || yerr.Line != 10 {
+		t.Errorf("expect error at line 10, found %v", err)
+	}
+}
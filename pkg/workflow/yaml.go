@@ -0,0 +1,321 @@
+package workflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML definition of a workflow graph, where ports are referred to by label:
+//
+//	nodes:
+//	  make:
+//	    processor: inputmaker
+//	  compile:
+//	    processor: compiler:auto
+//	  run:
+//	    processor: runner:stdio
+//	    key: true
+//	    params:
+//	      realtime: 1000
+//	edges:
+//	  - from: make.result
+//	    to: run.stdin
+//	  - from: compile.result
+//	    to: run.executable
+//	inbound:
+//	  - from: submission.source
+//	    to: compile.source
+//	  - from: tests.input
+//	    to: make.source
+//	  - from: static.limit
+//	    to: run.limit
+//	constants:
+//	  - value: "raw"
+//	    to: make.option
+//	groups: [hack]
+type yamlGraph struct {
+	Nodes     map[string]yamlNode `yaml:"nodes"`
+	Edges     []yamlEdge          `yaml:"edges,omitempty"`
+	Inbound   []yamlEdge          `yaml:"inbound,omitempty"`
+	Constants []yamlConstant      `yaml:"constants,omitempty"`
+	// datagroups besides built-in ones
+	Groups []yamlString `yaml:"groups,omitempty"`
+}
+
+type yamlNode struct {
+	Processor yamlString       `yaml:"processor"`
+	Key       bool             `yaml:"key,omitempty"`
+	Params    processor.Params `yaml:"params,omitempty"`
+}
+
+type yamlEdge struct {
+	// "node.label" or "group.field" for inbound
+	From yamlString `yaml:"from"`
+	// "node.label"
+	To yamlString `yaml:"to"`
+}
+
+type yamlConstant struct {
+	Value string `yaml:"value"`
+	// "node.label"
+	To yamlString `yaml:"to"`
+}
+
+// string scalar with its position
+type yamlString struct {
+	Value        string
+	Line, Column int
+}
+
+func (r *yamlString) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return &YAMLError{Line: value.Line, Column: value.Column, Msg: "expect a string"}
+	}
+	*r = yamlString{Value: value.Value, Line: value.Line, Column: value.Column}
+	return nil
+}
+
+func (r yamlString) MarshalYAML() (interface{}, error) {
+	return r.Value, nil
+}
+
+// Error of a YAML workflow definition.
+type YAMLError struct {
+	// position in the document, 0 if unknown
+	Line, Column int
+	Msg          string
+}
+
+func (r *YAMLError) Error() string {
+	if r.Line == 0 {
+		return r.Msg
+	}
+	if r.Column == 0 {
+		return fmt.Sprintf("line %d: %s", r.Line, r.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", r.Line, r.Column, r.Msg)
+}
+
+// convert error of yaml decoder, which only reports line, e.g.
+// "yaml: line 3: mapping values are not allowed in this context"
+func decodeError(err error) *YAMLError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	var terr *yaml.TypeError
+	if errors.As(err, &terr) && len(terr.Errors) > 0 {
+		msg = terr.Errors[0]
+	}
+	res := &YAMLError{Msg: msg}
+	var line int
+	if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+		res.Line = line
+		res.Msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+	}
+	return res
+}
+
+func (r yamlString) errorf(format string, a ...interface{}) error {
+	return &YAMLError{Line: r.Line, Column: r.Column, Msg: fmt.Sprintf(format, a...)}
+}
+
+// split "a.b" into "a" and "b"
+func (r yamlString) split(sep func(s, substr string) int) (string, string, error) {
+	i := sep(r.Value, ".")
+	if i <= 0 || i == len(r.Value)-1 {
+		return "", "", r.errorf("invalid port %q", r.Value)
+	}
+	return r.Value[:i], r.Value[i+1:], nil
+}
+
+// Load graph from YAML definition. The graph is built by Builder, whose
+// errors are reported with their position.
+func LoadYAML(serial []byte) (*WorkflowGraph, error) {
+	var def yamlGraph
+	dec := yaml.NewDecoder(bytes.NewReader(serial))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil {
+		var yerr *YAMLError
+		if errors.As(err, &yerr) {
+			return nil, yerr
+		}
+		if err == io.EOF {
+			return nil, &YAMLError{Msg: "empty document"}
+		}
+		return nil, decodeError(err)
+	}
+	// position of node names
+	var root yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(serial)).Decode(&root); err != nil {
+		return nil, decodeError(err)
+	}
+	pos := map[string]yamlString{}
+	if len(root.Content) > 0 {
+		if nodes := mappingValue(root.Content[0], "nodes"); nodes != nil {
+			for i := 0; i+1 < len(nodes.Content); i += 2 {
+				key := nodes.Content[i]
+				pos[key.Value] = yamlString{Value: key.Value, Line: key.Line, Column: key.Column}
+			}
+		}
+	}
+
+	var b Builder
+	groups := map[Groupname][]yamlString{}
+	for _, group := range def.Groups {
+		groups[Groupname(group.Value)] = append(groups[Groupname(group.Value)], group)
+		b.AddGroup(Groupname(group.Value))
+	}
+	for name, node := range def.Nodes {
+		b.SetNode(name, node.Processor.Value, node.Key)
+		for key, value := range node.Params {
+			b.SetParam(name, key, value)
+		}
+	}
+
+	// positions of ports in order of adding, see PortError
+	inputs, outputs := map[string][]yamlString{}, map[string][]yamlString{}
+	// position of the first inbound edge from each group
+	inbound := map[Groupname]yamlString{}
+	for _, edge := range def.Edges {
+		from, frlabel, err := edge.From.split(strings.LastIndex)
+		if err != nil {
+			return nil, err
+		}
+		to, tolabel, err := edge.To.split(strings.LastIndex)
+		if err != nil {
+			return nil, err
+		}
+		b.AddEdge(from, frlabel, to, tolabel)
+		outputs[from+"."+frlabel] = append(outputs[from+"."+frlabel], edge.From)
+		inputs[to+"."+tolabel] = append(inputs[to+"."+tolabel], edge.To)
+	}
+	for _, edge := range def.Inbound {
+		group, field, err := edge.From.split(strings.Index)
+		if err != nil {
+			return nil, err
+		}
+		to, tolabel, err := edge.To.split(strings.LastIndex)
+		if err != nil {
+			return nil, err
+		}
+		b.AddInbound(Groupname(group), field, to, tolabel)
+		if _, ok := inbound[Groupname(group)]; !ok {
+			inbound[Groupname(group)] = edge.From
+		}
+		inputs[to+"."+tolabel] = append(inputs[to+"."+tolabel], edge.To)
+	}
+	for _, constant := range def.Constants {
+		to, tolabel, err := constant.To.split(strings.LastIndex)
+		if err != nil {
+			return nil, err
+		}
+		b.AddConstant(constant.Value, to, tolabel)
+		inputs[to+"."+tolabel] = append(inputs[to+"."+tolabel], constant.To)
+	}
+
+	graph, err := b.WorkflowGraph()
+	if err == nil {
+		return graph, nil
+	}
+	// position of the error, zero if unknown
+	var at yamlString
+	var perr *PortError
+	var gerr *GroupError
+	var nerr *NodeError
+	if errors.As(err, &perr) {
+		ports := inputs
+		if perr.Output {
+			ports = outputs
+		}
+		if list := ports[perr.Node+"."+perr.Label]; perr.Nth < len(list) {
+			at = list[perr.Nth]
+		}
+	} else if errors.As(err, &gerr) {
+		if list := groups[gerr.Group]; gerr.Nth >= 0 && gerr.Nth < len(list) {
+			at = list[gerr.Nth]
+		} else if gerr.Nth == -1 {
+			at = inbound[gerr.Group]
+		}
+	} else if errors.As(err, &nerr) {
+		at = pos[nerr.Node]
+		// processor given but unknown
+		if proc := def.Nodes[nerr.Node].Processor; proc.Line > 0 && !processor.Exists(proc.Value) {
+			at = proc
+		}
+	}
+	return nil, at.errorf("%s", err)
+}
+
+// value of key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Load graph from YAML file.
+func LoadYAMLFile(path string) (*WorkflowGraph, error) {
+	serial, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadYAML(serial)
+}
+
+// Generate YAML definition, which is loaded by LoadYAML.
+func (r *WorkflowGraph) YAML() ([]byte, error) {
+	def := yamlGraph{Nodes: map[string]yamlNode{}}
+	for _, group := range r.Group {
+		def.Groups = append(def.Groups, yamlString{Value: string(group)})
+	}
+	for name, node := range r.Node {
+		def.Nodes[name] = yamlNode{Processor: yamlString{Value: node.ProcName}, Key: node.Key, Params: node.Params}
+	}
+	port := func(name string, labels []string, index int) (yamlString, error) {
+		if index < 0 || index >= len(labels) {
+			return yamlString{}, fmt.Errorf("invalid label index %d of node %s", index, name)
+		}
+		return yamlString{Value: name + "." + labels[index]}, nil
+	}
+	for _, edge := range r.Edge {
+		from, err := port(edge.From.Name, processor.OutputLabel(r.Node[edge.From.Name].ProcName), edge.From.LabelIndex)
+		if err != nil {
+			return nil, err
+		}
+		to, err := port(edge.To.Name, processor.InputLabel(r.Node[edge.To.Name].ProcName), edge.To.LabelIndex)
+		if err != nil {
+			return nil, err
+		}
+		def.Edges = append(def.Edges, yamlEdge{From: from, To: to})
+	}
+	for _, group := range r.groupNames() {
+		for _, field := range r.fieldNames(group) {
+			for _, bound := range (*r.Inbound[group])[field] {
+				to, err := port(bound.Name, processor.InputLabel(r.Node[bound.Name].ProcName), bound.LabelIndex)
+				if err != nil {
+					return nil, err
+				}
+				def.Inbound = append(def.Inbound, yamlEdge{From: yamlString{Value: string(group) + "." + field}, To: to})
+			}
+		}
+	}
+	for _, constant := range r.Constant {
+		to, err := port(constant.To.Name, processor.InputLabel(r.Node[constant.To.Name].ProcName), constant.To.LabelIndex)
+		if err != nil {
+			return nil, err
+		}
+		def.Constants = append(def.Constants, yamlConstant{Value: constant.Value, To: to})
+	}
+	return yaml.Marshal(def)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/sshwy/yaoj-core/pkg/private/processors"
+	"github.com/sshwy/yaoj-core/pkg/processor"
 	"github.com/sshwy/yaoj-core/pkg/utils"
 )
 
@@ -20,6 +21,38 @@ func renderStrArray(s []string) string {
 	return "[]string{" + strings.Join(s1, ",") + "}"
 }
 
+var kindName = map[processor.PortKind]string{
+	processor.Required: "Required",
+	processor.Optional: "Optional",
+	processor.Repeated: "Repeated",
+}
+
+// kinds are recorded only if some input is not required
+func renderKinds(kinds []processor.PortKind) (string, bool) {
+	s, ok := []string{}, false
+	for i, kind := range kinds {
+		if kind == processor.Repeated && i != len(kinds)-1 {
+			panic(fmt.Sprintf("repeated input %d is not the last one", i))
+		}
+		ok = ok || kind != processor.Required
+		s = append(s, kindName[kind])
+	}
+	return "[]PortKind{" + strings.Join(s, ",") + "}", ok
+}
+
+func renderInputParams(m map[string][][]string) string {
+	labels := []string{}
+	for label := range m {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	s := []string{}
+	for _, label := range labels {
+		s = append(s, renderStr(label)+":{"+strings.Join(utils.Map(m[label], renderStrArray), ",")+"}")
+	}
+	return "map[string][][]string{" + strings.Join(s, ",") + "}"
+}
+
 var start = `
 package processor
 
@@ -51,6 +84,15 @@ func main() {
 		inlab, oulab := procs[name].Label()
 		fmt.Fprintf(file, "\tinLabel[%s]=%s\n", renderStr(name), renderStrArray(inlab))
 		fmt.Fprintf(file, "\touLabel[%s]=%s\n", renderStr(name), renderStrArray(oulab))
+		if kinds, ok := renderKinds(processor.InputKinds(procs[name])); ok {
+			fmt.Fprintf(file, "\tinKind[%s]=%s\n", renderStr(name), kinds)
+		}
+		if p, ok := procs[name].(processor.ParamProcessor); ok {
+			fmt.Fprintf(file, "\tparamKeys[%s]=%s\n", renderStr(name), renderStrArray(p.ParamKeys()))
+		}
+		if p, ok := procs[name].(processor.ParamPortProcessor); ok {
+			fmt.Fprintf(file, "\tinParams[%s]=%s\n", renderStr(name), renderInputParams(p.InputParams()))
+		}
 	}
 	file.WriteString(end)
 }
@@ -10,7 +10,12 @@ func TestAll(t *testing.T) {
 	}
 	t.Run("MakeProbData", MakeProbData)
 	t.Run("LoadProblem", LoadProblem)
+	t.Run("ValidateProblem", ValidateProblem)
 	t.Run("DumpProblem", DumpProblem)
 	t.Run("ExtractProblem", ExtractProblem)
 	t.Run("RunProblem", RunProblem)
+	t.Run("RunProblemCancelled", RunProblemCancelled)
+	t.Run("RunProblemEvents", RunProblemEvents)
+	t.Run("RunProblemStrategy", RunProblemStrategy)
+	t.Run("RunProblemWorkers", RunProblemWorkers)
 }
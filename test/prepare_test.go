@@ -0,0 +1,79 @@
+package test_test
+
+import (
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/bitfield/script"
+	"github.com/sshwy/yaoj-core/pkg/private/run"
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/processor"
+	"github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+func TestPrepareProblem(t *testing.T) {
+	dir := t.TempDir()
+	prob, err := problem.NewProbData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script.Echo("1 2").WriteFile(path.Join(dir, "a.in"))
+	script.Echo("3").WriteFile(path.Join(dir, "b.in"))
+
+	var b workflow.Builder
+	// "validate" is independent of submission
+	b.SetNode("validate", "checker:wcmp", false)
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gtests, "input", "validate", "input")
+	b.AddInbound(workflow.Gtests, "input", "validate", "output")
+	b.AddInbound(workflow.Gtests, "answer", "validate", "answer")
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddInbound(workflow.Gtests, "answer", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prob.SetWkflGraph(graph.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	prob.Fullscore = 100
+	prob.Tests.Fields().Add("input")
+	prob.Tests.Fields().Add("answer")
+	for _, answer := range []string{"a.in", "b.in"} {
+		rcd := prob.Tests.Records().New()
+		if rcd["input"], err = prob.AddFile("a.in", path.Join(dir, "a.in")); err != nil {
+			t.Fatal(err)
+		}
+		if rcd["answer"], err = prob.AddFile(answer, path.Join(dir, answer)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// failures are cached along with their results, so preparing again
+	// reports them without running the nodes
+	run.SetCache(&run.InMemoryCache{})
+	for i := 0; i < 2; i++ {
+		cached := false
+		err = run.PrepareProblem(context.Background(), prob, t.TempDir(), run.WithTestWorkers(2),
+			run.WithObserver(func(event run.Event) {
+				if event.Type == run.EventNodeFinished && event.Cached &&
+					event.NodeResult != nil && event.NodeResult.Code != processor.Ok {
+					cached = true
+				}
+			}))
+		if cached != (i == 1) {
+			t.Errorf("run %d: unexpected cached failure %v", i, cached)
+		}
+		var failures run.PrepareErrors
+		if !errors.As(err, &failures) {
+			t.Fatalf("expect PrepareErrors, found %v", err)
+		}
+		t.Log(err)
+		if len(failures) != 1 || failures[0].Testcase != 1 || failures[0].Node != "validate" {
+			t.Errorf("unexpected failures %v", failures)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"context"
 	"path"
 	"testing"
 
@@ -98,6 +99,14 @@ func LoadProblem(t *testing.T) {
 	t.Log("submission", pp.Sprint(theProb.SubmConf()))
 }
 
+func ValidateProblem(t *testing.T) {
+	diags := problem.Validate(theProb.Data())
+	t.Log(diags)
+	if diags.HasError() {
+		t.Errorf("unexpected errors:\n%s", diags)
+	}
+}
+
 var problemDumpDir string
 
 func DumpProblem(t *testing.T) {
@@ -152,3 +161,112 @@ int main () {
 	}
 	t.Log(pp.Sprint(res))
 }
+
+func RunProblemCancelled(t *testing.T) {
+	dir := t.TempDir()
+	script.Echo("int main () { return 0; }").WriteFile(path.Join(dir, "src.cpp"))
+	subm := map[string]string{"source": path.Join(dir, "src.cpp")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := run.RunProblemContext(ctx, theProb.Data(), t.TempDir(), subm)
+	if err != context.Canceled {
+		t.Errorf("expect %v, found %v", context.Canceled, err)
+	}
+	if res == nil || !res.Cancelled {
+		t.Fatalf("expect cancelled result")
+	}
+	for _, subt := range res.Subtask {
+		for _, test := range subt.Testcase {
+			if test.Title != "Cancelled" {
+				t.Errorf("expect Cancelled, found %q", test.Title)
+			}
+		}
+	}
+}
+
+func RunProblemEvents(t *testing.T) {
+	dir := t.TempDir()
+	script.Echo("int main () { return 0; }").WriteFile(path.Join(dir, "src.cpp"))
+	subm := map[string]string{"source": path.Join(dir, "src.cpp")}
+
+	events := []run.Event{}
+	res, err := run.RunProblem(theProb.Data(), t.TempDir(), subm, run.WithObserver(func(event run.Event) {
+		events = append(events, event)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := map[run.EventType]int{}
+	for _, event := range events {
+		count[event.Type]++
+	}
+	t.Log(count)
+	if events[0].Type != run.EventRunStarted || events[len(events)-1].Type != run.EventRunFinished {
+		t.Errorf("unexpected first or last event")
+	}
+	if count[run.EventNodeStarted] != count[run.EventNodeFinished] {
+		t.Errorf("unpaired node events")
+	}
+	tests := 0
+	for _, subt := range res.Subtask {
+		tests += len(subt.Testcase)
+	}
+	if count[run.EventTestFinished] != tests {
+		t.Errorf("expect %d testcase events, found %d", tests, count[run.EventTestFinished])
+	}
+}
+
+func RunProblemStrategy(t *testing.T) {
+	dir := t.TempDir()
+	script.Echo("int main () { return 0; }").WriteFile(path.Join(dir, "src.cpp"))
+	subm := map[string]string{"source": path.Join(dir, "src.cpp")}
+
+	res, err := run.RunProblem(theProb.Data(), t.TempDir(), subm, run.WithStrategy(run.StopOnFirstFailure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := false
+	for _, subt := range res.Subtask {
+		for _, test := range subt.Testcase {
+			if failed && test.Title != "Skipped" {
+				t.Errorf("expect Skipped after failure, found %q", test.Title)
+			}
+			if test.Title != "Accepted" {
+				failed = true
+			}
+		}
+	}
+}
+
+func RunProblemWorkers(t *testing.T) {
+	dir := t.TempDir()
+	script.Echo("int main () { return 0; }").WriteFile(path.Join(dir, "src.cpp"))
+	subm := map[string]string{"source": path.Join(dir, "src.cpp")}
+
+	for _, strategy := range []run.Strategy{run.Full, run.StopOnFirstFailure} {
+		brief := []string{}
+		for _, workers := range []int{1, 4} {
+			tests := []int{}
+			res, err := run.RunProblem(theProb.Data(), t.TempDir(), subm, run.WithStrategy(strategy),
+				run.WithTestWorkers(workers), run.WithObserver(func(event run.Event) {
+					if event.Type == run.EventTestFinished {
+						tests = append(tests, event.Testcase)
+					}
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, id := range tests {
+				if i != id {
+					t.Errorf("testcases reported out of order: %v", tests)
+					break
+				}
+			}
+			brief = append(brief, res.Brief())
+		}
+		if brief[0] != brief[1] {
+			t.Errorf("results differ:\n%s\n%s", brief[0], brief[1])
+		}
+	}
+}
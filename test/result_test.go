@@ -0,0 +1,140 @@
+package test_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/utils"
+	"github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+func testcase(title string, score, fullscore float64, memory int64) workflow.Result {
+	return workflow.Result{ResultMeta: workflow.ResultMeta{
+		Title:     title,
+		Score:     score,
+		Fullscore: fullscore,
+		Time:      time.Second,
+		Memory:    utils.ByteValue(memory),
+	}}
+}
+
+func TestResultCalc(t *testing.T) {
+	subtasks := func() []problem.SubtResult {
+		return []problem.SubtResult{
+			{Subtaskid: "1", Fullscore: 40, Testcase: []workflow.Result{
+				testcase("Accepted", 20, 20, 10),
+				testcase("Partially Correct", 5, 20, 30),
+			}},
+			{Subtaskid: "2", Fullscore: 60, Testcase: []workflow.Result{
+				testcase("Accepted", 30, 30, 20),
+				testcase("Accepted", 30, 30, 20),
+			}},
+		}
+	}
+	for method, score := range map[problem.CalcMethod]float64{
+		problem.Mmin: 10 + 60,
+		problem.Mmax: 40 + 60,
+		problem.Msum: 25 + 60,
+	} {
+		res := problem.Result{
+			IsSubtask:  true,
+			CalcMethod: method,
+			Fullscore:  100,
+			Subtask:    subtasks(),
+		}
+		res.Calc()
+		t.Log(res.Brief())
+		if res.Score != score {
+			t.Errorf("method %d: expect score %v, found %v", method, score, res.Score)
+		}
+		if res.Title != "Partially Correct" || res.Subtask[1].Title != "Accepted" {
+			t.Errorf("method %d: unexpected title %q", method, res.Title)
+		}
+		if res.Time != 4*time.Second || res.Memory != 30 {
+			t.Errorf("method %d: unexpected time %v or memory %v", method, res.Time, res.Memory)
+		}
+	}
+}
+
+func TestNoSubtaskCalc(t *testing.T) {
+	// testcases are summed up without subtasks, whatever CalcMethod is
+	for _, method := range []problem.CalcMethod{problem.Mmin, problem.Mmax, problem.Msum} {
+		res := problem.Result{
+			CalcMethod: method,
+			Fullscore:  100,
+			Subtask: []problem.SubtResult{
+				{Fullscore: 100, Testcase: []workflow.Result{
+					testcase("Accepted", 25, 25, 10),
+					testcase("Wrong Answer", 0, 25, 10),
+					testcase("Accepted", 25, 25, 10),
+					testcase("Accepted", 25, 25, 10),
+				}},
+			},
+		}
+		res.Calc()
+		if res.Score != 75 || res.Title != "Wrong Answer" {
+			t.Errorf("method %d: unexpected result %v %q", method, res.Score, res.Title)
+		}
+	}
+}
+
+func TestSubtaskDepend(t *testing.T) {
+	res := problem.Result{
+		IsSubtask:  true,
+		CalcMethod: problem.Mmin,
+		Fullscore:  100,
+		Subtask: []problem.SubtResult{
+			{Subtaskid: "1", Fullscore: 40, Testcase: []workflow.Result{
+				testcase("Accepted", 20, 20, 10),
+				testcase("Partially Correct", 10, 20, 10),
+			}},
+			{Subtaskid: "2", Fullscore: 60, Depend: []int{0}, Testcase: []workflow.Result{
+				testcase("Accepted", 60, 60, 10),
+			}},
+		},
+	}
+	res.Calc()
+	// subtask 2 is capped by ratio 0.5 of subtask 1
+	if res.Subtask[1].Score != 30 || res.Score != 20+30 {
+		t.Errorf("unexpected score %v, %v", res.Subtask[1].Score, res.Score)
+	}
+
+	prob, err := problem.NewProbData(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prob.Subtasks.Fields().Add("_subtaskid")
+	prob.Subtasks.Fields().Add("_depend")
+	for _, v := range [][2]string{{"1", ""}, {"2", "1, 3"}, {"3", "1"}} {
+		rcd := prob.Subtasks.Records().New()
+		rcd["_subtaskid"], rcd["_depend"] = v[0], v[1]
+	}
+	order, err := prob.SubtaskOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != 0 || order[1] != 2 || order[2] != 1 {
+		t.Errorf("unexpected order %v", order)
+	}
+	prob.Subtasks.Record[0]["_depend"] = "2"
+	if _, err := prob.SubtaskOrder(); err == nil {
+		t.Errorf("expect cyclic dependency error")
+	}
+}
+
+func TestSkippedCalc(t *testing.T) {
+	subt := problem.SubtResult{Fullscore: 40, Testcase: []workflow.Result{
+		testcase("Partially Correct", 10, 20, 10),
+		testcase("Skipped", 0, 20, 0),
+	}}
+	subt.Calc(problem.Mmin)
+	if subt.Score != 20 || subt.Title != "Partially Correct" {
+		t.Errorf("unexpected result %v %q", subt.Score, subt.Title)
+	}
+	subt.Testcase[0] = testcase("Skipped", 0, 20, 0)
+	subt.Calc(problem.Mmin)
+	if subt.Score != 0 || subt.Title != "Skipped" {
+		t.Errorf("unexpected result %v %q", subt.Score, subt.Title)
+	}
+}
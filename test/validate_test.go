@@ -0,0 +1,91 @@
+package test_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/bitfield/script"
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/workflow"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	prob, err := problem.NewProbData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script.Echo("1 2").WriteFile(path.Join(dir, "a.in"))
+	script.Echo("3").WriteFile(path.Join(dir, "a.ans"))
+
+	var b workflow.Builder
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddInbound(workflow.Gtests, "answer", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prob.SetWkflGraph(graph.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	prob.Submission["source"] = problem.SubmLimit{Length: 1024}
+
+	prob.Fullscore = 100
+	prob.Tests.Fields().Add("input")
+	prob.Tests.Fields().Add("answer")
+	prob.Tests.Fields().Add("_subtaskid")
+	prob.Subtasks.Fields().Add("_subtaskid")
+	prob.Subtasks.Fields().Add("_score")
+	for _, id := range []string{"1", "2"} {
+		subtask := prob.Subtasks.Records().New()
+		subtask["_subtaskid"] = id
+		subtask["_score"] = "50"
+		test := prob.Tests.Records().New()
+		test["_subtaskid"] = id
+		if test["input"], err = prob.AddFile("a.in", path.Join(dir, "a.in")); err != nil {
+			t.Fatal(err)
+		}
+		if test["answer"], err = prob.AddFile("a.ans", path.Join(dir, "a.ans")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diags := problem.Validate(prob)
+	t.Log(diags)
+	if diags.HasError() || len(diags.Filter(problem.Warning)) > 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diags)
+	}
+	// "input" is not used by workflow
+	if len(diags) != 1 || diags[0].Where != "tests input" {
+		t.Errorf("unexpected diagnostics:\n%s", diags)
+	}
+
+	prob.Tests.Record[0]["input"] = "missing.in"
+	prob.Tests.Record[1]["_subtaskid"] = "3"
+	prob.Subtasks.Record[1]["_score"] = "fifty"
+	prob.Tests.Fields().Delete("answer")
+
+	diags = problem.Validate(prob)
+	t.Log(diags)
+	expect := map[string]bool{
+		"tests #0 input":        false,
+		"tests #1":              false,
+		"subtasks #1 _score":    false,
+		"workflow tests answer": false,
+		"subtasks #1":           false, // no testcase
+	}
+	for _, diag := range diags {
+		if _, ok := expect[diag.Where]; ok {
+			expect[diag.Where] = true
+		}
+	}
+	for where, found := range expect {
+		if !found {
+			t.Errorf("expect diagnostic at %q", where)
+		}
+	}
+	if !diags.HasError() {
+		t.Errorf("expect errors")
+	}
+}
@@ -1,9 +1,18 @@
 package test_test
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
 	"testing"
 
 	"github.com/k0kubun/pp/v3"
+	"github.com/sshwy/yaoj-core/pkg/private/processors"
+	"github.com/sshwy/yaoj-core/pkg/private/run"
+	"github.com/sshwy/yaoj-core/pkg/problem"
+	"github.com/sshwy/yaoj-core/pkg/processor"
 	"github.com/sshwy/yaoj-core/pkg/workflow"
 )
 
@@ -67,3 +76,181 @@ func MakeWorkflowGraph(t *testing.T) {
 		t.Log(string(w2.Serialize()))
 	*/
 }
+
+func TestConstantInbound(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddConstant("3\n", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err = workflow.Load(graph.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := workflow.Workflow{WorkflowGraph: graph, Analyzer: workflow.DefaultAnalyzer{}}
+
+	dir := t.TempDir()
+	for _, c := range []struct {
+		out      string
+		accepted bool
+	}{{"3", true}, {"4", false}} {
+		out := path.Join(dir, "out")
+		if err := os.WriteFile(out, []byte(c.out), 0644); err != nil {
+			t.Fatal(err)
+		}
+		res, err := run.RunWorkflow(w, t.TempDir(), map[workflow.Groupname]*map[string]string{
+			workflow.Gsubm: {"source": out},
+		}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (res.Title == "Accepted") != c.accepted {
+			t.Errorf("output %q: unexpected result %s", c.out, res.Title)
+		}
+	}
+}
+
+func TestCustomGroup(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddInbound("hack", "answer", "check", "ans")
+	if _, err := b.WorkflowGraph(); err == nil {
+		t.Errorf("expect error for undefined group")
+	}
+	b.AddGroup("hack")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	prob, err := problem.NewProbData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prob.SetWkflGraph(graph.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	prob.Fullscore = 100
+	prob.Tests.Records().New()
+	if diags := problem.Validate(prob); diags.HasError() {
+		t.Errorf("unexpected errors:\n%s", diags)
+	}
+
+	out, ans := path.Join(dir, "out"), path.Join(dir, "ans")
+	os.WriteFile(out, []byte("3"), 0644)
+	os.WriteFile(ans, []byte("3"), 0644)
+	submission := map[string]string{"source": out}
+	if _, err := run.RunProblem(prob, t.TempDir(), submission); err == nil {
+		t.Errorf("expect error for missing datagroup")
+	}
+	res, err := run.RunProblem(prob, t.TempDir(), submission,
+		run.WithGroup("hack", map[string]string{"answer": ans}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Score != res.Fullscore {
+		t.Errorf("unexpected result %s", res.Brief())
+	}
+	// nodes depending on hack are not prepared
+	if err := run.PrepareProblem(context.Background(), prob, t.TempDir()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptionalInput(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("make", "inputmaker", false)
+	b.SetNode("check", "checker:hcmp", true)
+	b.AddInbound(workflow.Gtests, "answer", "make", "source")
+	b.AddInbound(workflow.Gtests, "option", "make", "option")
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddEdge("make", "result", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := workflow.Workflow{WorkflowGraph: graph, Analyzer: workflow.DefaultAnalyzer{}}
+
+	dir := t.TempDir()
+	out, ans, option := path.Join(dir, "out"), path.Join(dir, "ans"), path.Join(dir, "option")
+	os.WriteFile(out, []byte("3"), 0644)
+	os.WriteFile(ans, []byte("3"), 0644)
+	for _, c := range []struct {
+		option   string
+		accepted bool
+	}{{"raw", true}, {"generator", false}} {
+		os.WriteFile(option, []byte(c.option), 0644)
+		res, err := run.RunWorkflow(w, t.TempDir(), map[workflow.Groupname]*map[string]string{
+			workflow.Gtests: {"answer": ans, "option": option},
+			workflow.Gsubm:  {"source": out},
+		}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (res.Score == res.Fullscore) != c.accepted {
+			t.Errorf("option %q: unexpected result %s", c.option, res.Title)
+		}
+	}
+}
+
+func TestParamsWithoutFile(t *testing.T) {
+	var b workflow.Builder
+	b.SetNode("make", "inputmaker", false)
+	b.SetNode("check", "checker:hcmp", true)
+	// "option" is given by params instead of a file
+	b.SetParam("make", "option", "raw")
+	b.AddInbound(workflow.Gtests, "answer", "make", "source")
+	b.AddInbound(workflow.Gsubm, "source", "check", "out")
+	b.AddEdge("make", "result", "check", "ans")
+	graph, err := b.WorkflowGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := workflow.Workflow{WorkflowGraph: graph, Analyzer: workflow.DefaultAnalyzer{}}
+
+	dir := t.TempDir()
+	out, ans := path.Join(dir, "out"), path.Join(dir, "ans")
+	os.WriteFile(out, []byte("3"), 0644)
+	os.WriteFile(ans, []byte("3"), 0644)
+	res, err := run.RunWorkflow(w, t.TempDir(), map[workflow.Groupname]*map[string]string{
+		workflow.Gtests: {"answer": ans},
+		workflow.Gsubm:  {"source": out},
+	}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Score != res.Fullscore {
+		t.Errorf("unexpected result %s", res.Title)
+	}
+}
+
+func TestRegisterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("checker:hcmp%d", i)
+		go func() {
+			defer wg.Done()
+			processor.Register(name, processors.CheckerHcmp{})
+		}()
+		go func() {
+			defer wg.Done()
+			var b workflow.Builder
+			b.SetNode("check", "checker:hcmp", true)
+			b.AddInbound(workflow.Gsubm, "source", "check", "out")
+			b.AddInbound(workflow.Gtests, "answer", "check", "ans")
+			if _, err := b.WorkflowGraph(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if !processor.Exists("checker:hcmp7") {
+		t.Error("checker:hcmp7 not registered")
+	}
+}